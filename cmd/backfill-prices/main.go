@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/services"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// backfill-prices loads historical daily IDR prices from the CoinGecko
+// market_chart API into the price_history table. Point -base-url at a local
+// stub to backfill without calling CoinGecko.
+//
+//	go run ./cmd/backfill-prices -days 365 -symbols BTC,ETH
+func main() {
+	cfg := config.LoadConfig()
+
+	days := flag.Int("days", 365, "number of past days to load")
+	symbols := flag.String("symbols", "", "comma separated symbols, default every enabled asset")
+	baseURL := flag.String("base-url", cfg.CoinGecko.APIURL, "CoinGecko API base URL")
+	delay := flag.Duration("delay", 2*time.Second, "pause between assets to stay under the rate limit")
+	flag.Parse()
+
+	if *days < 1 {
+		log.Fatal("-days must be at least 1")
+	}
+
+	db, err := config.InitDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	assetRegistry := services.NewAssetRegistry(repository.NewAssetRepository(db))
+	if err := assetRegistry.Load(); err != nil {
+		log.Fatalf("Failed to load assets: %v", err)
+	}
+
+	targets := assetRegistry.PricedSymbols()
+	if *symbols != "" {
+		targets = nil
+		for _, symbol := range strings.Split(*symbols, ",") {
+			if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+				targets = append(targets, symbol)
+			}
+		}
+	}
+
+	provider := services.NewCoinGeckoProvider(*baseURL, assetRegistry)
+	backfillService := services.NewPriceBackfillService(provider, assetRegistry, repository.NewPriceHistoryRepository(db))
+
+	ctx := context.Background()
+	failed := 0
+	for i, symbol := range targets {
+		if i > 0 {
+			time.Sleep(*delay)
+		}
+
+		added, err := backfillService.Backfill(ctx, symbol, *days)
+		if err != nil {
+			log.Printf("Backfill %s failed: %v", symbol, err)
+			failed++
+			continue
+		}
+		log.Printf("Backfill %s: %d daily prices added", symbol, added)
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
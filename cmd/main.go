@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"crypto-wallet-service/config"
 	"crypto-wallet-service/internal/handlers"
+	"crypto-wallet-service/internal/middleware"
 	"crypto-wallet-service/internal/repository"
 	"crypto-wallet-service/internal/routes"
+	"crypto-wallet-service/internal/secrets"
 	"crypto-wallet-service/internal/services"
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	
+
 	cfg := config.LoadConfig()
 
+	if err := middleware.LoadSigningKeys(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
 
 	db, err := config.InitDatabase(cfg.Database)
 	if err != nil {
@@ -31,27 +39,95 @@ func main() {
 	userRepo := repository.NewUserRepository(db)
 	walletRepo := repository.NewWalletRepository(db)
 	transactionRepo := repository.NewTransactionRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	assetRepo := repository.NewAssetRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	priceHistoryRepo := repository.NewPriceHistoryRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	unitOfWork := repository.NewUnitOfWork(db)
+	if err := middleware.UseAPIKeys(apiKeyRepo); err != nil {
+		log.Fatalf("Failed to set up API keys: %v", err)
+	}
 
 	
-	coinGeckoService := services.NewCoinGeckoService(redisClient)
-	walletService := services.NewWalletService(walletRepo, transactionRepo, coinGeckoService, db)
+	assetRegistry := services.NewAssetRegistry(assetRepo)
+	if err := assetRegistry.Load(); err != nil {
+		log.Fatalf("Failed to load assets: %v", err)
+	}
+	if cfg.Asset.ReloadSeconds > 0 {
+		assetRegistry.StartAutoReload(context.Background(), time.Duration(cfg.Asset.ReloadSeconds)*time.Second)
+	}
 
+	priceProvider, err := services.NewPriceProviderFromConfig(cfg, assetRegistry)
+	if err != nil {
+		log.Fatalf("Failed to initialize price providers: %v", err)
+	}
 
-	authHandler := handlers.NewAuthHandler(userRepo)
+	mailer, err := services.NewMailerFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+	priceService := services.NewPriceService(priceProvider, assetRegistry, redisClient, priceHistoryRepo)
+	priceRefresher := services.NewPriceRefresher(priceService, redisClient)
+	totpSecrets, err := secrets.Load("MFA_ENCRYPTION_KEY", cfg.MFA.EncryptionKey, "totp-secret")
+	if err != nil {
+		log.Fatalf("Failed to set up TOTP secret encryption: %v", err)
+	}
+	mfaService := services.NewMFAService(userRepo, recoveryCodeRepo, redisClient, totpSecrets)
+	walletService := services.NewWalletService(userRepo, walletRepo, transactionRepo, priceService, mfaService, assetRegistry, unitOfWork)
+	ledgerService := services.NewLedgerService(walletRepo, ledgerRepo, unitOfWork)
+	reconciliationService := services.NewReconciliationService(walletRepo, transactionRepo, unitOfWork)
+	tokenService := services.NewTokenService(userRepo, refreshTokenRepo)
+	accountService := services.NewAccountService(userRepo, userTokenRepo, apiKeyRepo, tokenService, mailer)
+	taxReportService := services.NewTaxReportService(transactionRepo)
+	importService := services.NewImportService(transactionRepo, assetRegistry, unitOfWork)
+	transactionService := services.NewTransactionService(transactionRepo, userRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+
+	// Wallets created before the ledger existed get an opening balance entry
+	if err := ledgerService.EnsureOpeningBalances(); err != nil {
+		log.Fatalf("Failed to initialize ledger: %v", err)
+	}
+
+	if cfg.Reconcile.IntervalMinutes > 0 {
+		interval := time.Duration(cfg.Reconcile.IntervalMinutes) * time.Minute
+		reconciliationService.StartScheduler(context.Background(), interval, cfg.Reconcile.AutoFreeze)
+		log.Printf("Reconciliation job scheduled every %s", interval)
+	}
+
+	if cfg.Price.RefreshSeconds > 0 {
+		priceRefresher.Start(context.Background())
+		log.Printf("Price refresher running every %ds", cfg.Price.RefreshSeconds)
+	}
+
+
+	authHandler := handlers.NewAuthHandler(userRepo, tokenService, mfaService, accountService)
 	walletHandler := handlers.NewWalletHandler(walletService)
-	transactionHandler := handlers.NewTransactionHandler(transactionRepo)
+	transactionHandler := handlers.NewTransactionHandler(transactionRepo, transactionService, importService)
+	adminHandler := handlers.NewAdminHandler(reconciliationService)
+	assetHandler := handlers.NewAssetHandler(assetRegistry)
+	reportHandler := handlers.NewReportHandler(taxReportService)
+	healthHandler := handlers.NewHealthHandler(priceRefresher)
+	priceHandler := handlers.NewPriceHandler(priceService, assetRegistry)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
 
 	
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key, X-API-Key, X-API-Key-ID, X-API-Signature, X-API-Timestamp, X-API-Nonce")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -63,7 +139,7 @@ func main() {
 	})
 
 
-	routes.SetupRoutes(router, authHandler, walletHandler, transactionHandler)
+	routes.SetupRoutes(router, authHandler, walletHandler, transactionHandler, adminHandler, assetHandler, reportHandler, healthHandler, priceHandler, mfaHandler, apiKeyHandler, userRepo)
 
 
 	log.Printf("Server starting on port %s...", cfg.Server.Port)
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/services"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+)
+
+// reconcile compares every wallet balance with the balance recomputed from
+// the transactions table and prints the drift report as JSON.
+//
+//	go run ./cmd/reconcile -freeze -fail-on-drift -out report.json
+func main() {
+	freeze := flag.Bool("freeze", false, "freeze every wallet that has drifted")
+	rebuild := flag.Bool("rebuild", false, "rewrite wallet balances from ledger postings before reconciling")
+	failOnDrift := flag.Bool("fail-on-drift", false, "exit with status 1 when drift is found")
+	out := flag.String("out", "", "write the JSON report to this file instead of stdout")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+
+	db, err := config.InitDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	walletRepo := repository.NewWalletRepository(db)
+	transactionRepo := repository.NewTransactionRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	unitOfWork := repository.NewUnitOfWork(db)
+
+	if *rebuild {
+		ledgerService := services.NewLedgerService(walletRepo, ledgerRepo, unitOfWork)
+		corrections, err := ledgerService.RebuildBalances()
+		if err != nil {
+			log.Fatalf("Failed to rebuild balances: %v", err)
+		}
+		log.Printf("Rebuilt wallet balances from ledger, %d corrected", len(corrections))
+		for _, c := range corrections {
+			log.Printf("  user %s %s: %s -> %s", c.UserID, c.Currency, c.PreviousBalance, c.LedgerBalance)
+		}
+	}
+
+	reconciliationService := services.NewReconciliationService(walletRepo, transactionRepo, unitOfWork)
+	report, err := reconciliationService.Run(*freeze)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	output := os.Stdout
+	if *out != "" {
+		output, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create report file: %v", err)
+		}
+		defer output.Close()
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	if *failOnDrift && report.DriftCount > 0 {
+		os.Exit(1)
+	}
+}
@@ -6,28 +6,41 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"crypto-wallet-service/internal/models"
 
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	Redis     RedisConfig
-	JWT       JWTConfig
-	CoinGecko CoinGeckoConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	JWT         JWTConfig
+	CoinGecko   CoinGeckoConfig
+	Idempotency IdempotencyConfig
+	Fee         FeeConfig
+	Reconcile   ReconcileConfig
+	Price       PriceConfig
+	Asset       AssetConfig
+	Tax         TaxConfig
+	MFA         MFAConfig
+	APIKey      APIKeyConfig
+	Mail        MailConfig
+	Account     AccountConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port           string
+	Mode           string
+	TrustedProxies []string // proxy IPs or CIDRs whose X-Forwarded-For is believed, none by default
 }
 
 type DatabaseConfig struct {
@@ -46,8 +59,15 @@ type RedisConfig struct {
 	DB       int
 }
 
+// JWTConfig controls access token signing. Without Keys tokens are signed
+// with HS256 and Secret; with Keys they are signed with RSA or Ed25519 PEM
+// keys identified by kid and published as a JWKS.
 type JWTConfig struct {
-	Secret string
+	Secret            string
+	AccessTTLMinutes  int
+	RefreshTTLHours   int
+	Keys              []string // kid=path.pem[@RFC 3339 time the key starts signing]
+	KeyOverlapMinutes int      // how long a replaced key still verifies tokens
 }
 
 type CoinGeckoConfig struct {
@@ -55,6 +75,81 @@ type CoinGeckoConfig struct {
 	CacheDurationSeconds int
 }
 
+type PriceConfig struct {
+	Providers                []string // priority order, e.g. coingecko,binance,static
+	Mode                     string   // failover or median
+	MedianMinSources         int
+	BinanceAPIURL            string
+	BinanceQuotes            []string // vs:QUOTE pairs, e.g. usd:USDT
+	StaticFile               string
+	LocalCacheSeconds        int // in-process cache in front of Redis, 0 disables
+	MaxStalenessSeconds      int // how long the last known good price may be served
+	RefreshSeconds           int // background refresher interval, 0 disables
+	RefreshMaxBackoffSeconds int
+}
+
+type AssetConfig struct {
+	ReloadSeconds int // how often each instance reloads the asset registry, 0 disables
+}
+
+type IdempotencyConfig struct {
+	TTLSeconds int
+}
+
+type FeeConfig struct {
+	WithdrawPercent decimal.Decimal
+}
+
+// TaxConfig holds the Indonesian crypto tax rates, in percent of the IDR
+// value of each disposal
+type TaxConfig struct {
+	PPhFinalPercent decimal.Decimal // PPh final (Pasal 22)
+	PPNPercent      decimal.Decimal
+}
+
+// MFAConfig controls TOTP two-factor authentication. Withdrawals and
+// transfers worth more than WithdrawThresholdIDR need a fresh TOTP code.
+// TOTP secrets are stored encrypted with EncryptionKey (AES-256-GCM).
+type MFAConfig struct {
+	Issuer               string // shown in authenticator apps
+	EncryptionKey        string // base64, 32 bytes
+	ChallengeTTLSeconds  int    // lifetime of the login MFA challenge token
+	WithdrawThresholdIDR decimal.Decimal
+	RequireEnrollment    bool // refuse such withdrawals for users without 2FA
+}
+
+// APIKeyConfig controls HMAC signed requests made with API keys. Signing
+// secrets are stored encrypted with EncryptionKey (AES-256-GCM).
+type APIKeyConfig struct {
+	EncryptionKey           string // base64, 32 bytes
+	SignatureMaxSkewSeconds int    // how far a request timestamp may be from now
+}
+
+// MailConfig selects how emails are sent: "smtp", or "log" which writes
+// them to the log (and to LogDir when set) for local development
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	LogDir       string
+}
+
+// AccountConfig controls email verification and password reset tokens
+type AccountConfig struct {
+	AppBaseURL              string // links in emails point here
+	VerificationTTLHours    int
+	PasswordResetTTLMinutes int
+	MaxEmailsPerHour        int // per user and purpose
+}
+
+type ReconcileConfig struct {
+	IntervalMinutes int // 0 disables the in-process job
+	AutoFreeze      bool
+}
+
 var (
 	AppConfig   *Config
 	DB          *gorm.DB
@@ -70,11 +165,34 @@ func LoadConfig() *Config {
 
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
 	cacheDuration, _ := strconv.Atoi(getEnv("CACHE_DURATION_SECONDS", "60"))
+	idempotencyTTL, _ := strconv.Atoi(getEnv("IDEMPOTENCY_TTL_SECONDS", "86400"))
+	withdrawFeePercent, _ := decimal.NewFromString(getEnv("WITHDRAW_FEE_PERCENT", "0"))
+	reconcileInterval, _ := strconv.Atoi(getEnv("RECONCILE_INTERVAL_MINUTES", "0"))
+	reconcileAutoFreeze, _ := strconv.ParseBool(getEnv("RECONCILE_AUTO_FREEZE", "false"))
+	medianMinSources, _ := strconv.Atoi(getEnv("PRICE_MEDIAN_MIN_SOURCES", "2"))
+	localPriceCache, _ := strconv.Atoi(getEnv("PRICE_LOCAL_CACHE_SECONDS", "5"))
+	priceMaxStaleness, _ := strconv.Atoi(getEnv("PRICE_MAX_STALENESS_SECONDS", "900"))
+	priceRefresh, _ := strconv.Atoi(getEnv("PRICE_REFRESH_SECONDS", "60"))
+	priceRefreshMaxBackoff, _ := strconv.Atoi(getEnv("PRICE_REFRESH_MAX_BACKOFF_SECONDS", "600"))
+	assetReload, _ := strconv.Atoi(getEnv("ASSET_RELOAD_SECONDS", "60"))
+	accessTTL, _ := strconv.Atoi(getEnv("JWT_ACCESS_TTL_MINUTES", "15"))
+	refreshTTL, _ := strconv.Atoi(getEnv("JWT_REFRESH_TTL_HOURS", "720"))
+	jwtKeyOverlap, _ := strconv.Atoi(getEnv("JWT_KEY_OVERLAP_MINUTES", "0"))
+	taxPPhFinal, _ := decimal.NewFromString(getEnv("TAX_PPH_FINAL_PERCENT", "0.1"))
+	taxPPN, _ := decimal.NewFromString(getEnv("TAX_PPN_PERCENT", "0.11"))
+	mfaChallengeTTL, _ := strconv.Atoi(getEnv("MFA_CHALLENGE_TTL_SECONDS", "300"))
+	mfaWithdrawThreshold, _ := decimal.NewFromString(getEnv("MFA_WITHDRAW_THRESHOLD_IDR", "10000000"))
+	mfaRequireEnrollment, _ := strconv.ParseBool(getEnv("MFA_REQUIRE_ENROLLMENT", "false"))
+	signatureMaxSkew, _ := strconv.Atoi(getEnv("API_SIGNATURE_MAX_SKEW_SECONDS", "30"))
+	verificationTTL, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_TTL_HOURS", "48"))
+	passwordResetTTL, _ := strconv.Atoi(getEnv("PASSWORD_RESET_TTL_MINUTES", "30"))
+	maxAccountEmails, _ := strconv.Atoi(getEnv("ACCOUNT_EMAILS_PER_HOUR", "3"))
 
 	config := &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			Mode:           getEnv("GIN_MODE", "debug"),
+			TrustedProxies: splitList(getEnv("TRUSTED_PROXIES", "")),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -91,12 +209,71 @@ func LoadConfig() *Config {
 			DB:       redisDB,
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
+			Secret:            getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
+			AccessTTLMinutes:  accessTTL,
+			RefreshTTLHours:   refreshTTL,
+			Keys:              splitList(getEnv("JWT_KEYS", "")),
+			KeyOverlapMinutes: jwtKeyOverlap,
 		},
 		CoinGecko: CoinGeckoConfig{
 			APIURL:               getEnv("COINGECKO_API_URL", "https://api.coingecko.com/api/v3"),
 			CacheDurationSeconds: cacheDuration,
 		},
+		Price: PriceConfig{
+			Providers:                splitList(getEnv("PRICE_PROVIDERS", "coingecko")),
+			Mode:                     getEnv("PRICE_MODE", "failover"),
+			MedianMinSources:         medianMinSources,
+			BinanceAPIURL:            getEnv("BINANCE_API_URL", "https://api.binance.com"),
+			BinanceQuotes:            splitList(getEnv("BINANCE_QUOTES", "usd:USDT")),
+			StaticFile:               getEnv("PRICE_STATIC_FILE", "prices.json"),
+			LocalCacheSeconds:        localPriceCache,
+			MaxStalenessSeconds:      priceMaxStaleness,
+			RefreshSeconds:           priceRefresh,
+			RefreshMaxBackoffSeconds: priceRefreshMaxBackoff,
+		},
+		Asset: AssetConfig{
+			ReloadSeconds: assetReload,
+		},
+		Idempotency: IdempotencyConfig{
+			TTLSeconds: idempotencyTTL,
+		},
+		Fee: FeeConfig{
+			WithdrawPercent: withdrawFeePercent,
+		},
+		Reconcile: ReconcileConfig{
+			IntervalMinutes: reconcileInterval,
+			AutoFreeze:      reconcileAutoFreeze,
+		},
+		Tax: TaxConfig{
+			PPhFinalPercent: taxPPhFinal,
+			PPNPercent:      taxPPN,
+		},
+		MFA: MFAConfig{
+			Issuer:               getEnv("MFA_ISSUER", "Crypto Wallet"),
+			EncryptionKey:        getEnv("MFA_ENCRYPTION_KEY", ""),
+			ChallengeTTLSeconds:  mfaChallengeTTL,
+			WithdrawThresholdIDR: mfaWithdrawThreshold,
+			RequireEnrollment:    mfaRequireEnrollment,
+		},
+		APIKey: APIKeyConfig{
+			EncryptionKey:           getEnv("API_KEY_ENCRYPTION_KEY", ""),
+			SignatureMaxSkewSeconds: signatureMaxSkew,
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "Crypto Wallet <no-reply@localhost>"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			LogDir:       getEnv("MAIL_LOG_DIR", ""),
+		},
+		Account: AccountConfig{
+			AppBaseURL:              getEnv("APP_BASE_URL", "http://localhost:8080"),
+			VerificationTTLHours:    verificationTTL,
+			PasswordResetTTLMinutes: passwordResetTTL,
+			MaxEmailsPerHour:        maxAccountEmails,
+		},
 	}
 
 	AppConfig = config
@@ -126,6 +303,15 @@ func InitDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
 		&models.User{},
 		&models.Wallet{},
 		&models.Transaction{},
+		&models.LedgerAccount{},
+		&models.JournalEntry{},
+		&models.Posting{},
+		&models.Asset{},
+		&models.RefreshToken{},
+		&models.PriceHistory{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.UserToken{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
@@ -164,6 +350,84 @@ func getEnv(key, fallback string) string {
 }
 
 
+// splitList parses a comma separated env value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+
 func GetCacheDuration() time.Duration {
 	return time.Duration(AppConfig.CoinGecko.CacheDurationSeconds) * time.Second
 }
+
+
+// GetLocalPriceCacheDuration is never longer than the Redis cache duration
+func GetLocalPriceCacheDuration() time.Duration {
+	local := time.Duration(AppConfig.Price.LocalCacheSeconds) * time.Second
+	if shared := GetCacheDuration(); local > shared {
+		return shared
+	}
+	return local
+}
+
+
+// GetPriceMaxStaleness is never shorter than the cache duration
+func GetPriceMaxStaleness() time.Duration {
+	maxStaleness := time.Duration(AppConfig.Price.MaxStalenessSeconds) * time.Second
+	if fresh := GetCacheDuration(); maxStaleness < fresh {
+		return fresh
+	}
+	return maxStaleness
+}
+
+
+func GetAccessTokenTTL() time.Duration {
+	return time.Duration(AppConfig.JWT.AccessTTLMinutes) * time.Minute
+}
+
+
+func GetRefreshTokenTTL() time.Duration {
+	return time.Duration(AppConfig.JWT.RefreshTTLHours) * time.Hour
+}
+
+
+// GetJWTKeyOverlap is how long a signing key keeps verifying tokens after
+// the next key took over. It defaults to the access token TTL so no token
+// signed just before a rotation is rejected.
+func GetJWTKeyOverlap() time.Duration {
+	if AppConfig.JWT.KeyOverlapMinutes <= 0 {
+		return GetAccessTokenTTL()
+	}
+	return time.Duration(AppConfig.JWT.KeyOverlapMinutes) * time.Minute
+}
+
+
+func GetSignatureMaxSkew() time.Duration {
+	return time.Duration(AppConfig.APIKey.SignatureMaxSkewSeconds) * time.Second
+}
+
+
+func GetVerificationTokenTTL() time.Duration {
+	return time.Duration(AppConfig.Account.VerificationTTLHours) * time.Hour
+}
+
+
+func GetPasswordResetTokenTTL() time.Duration {
+	return time.Duration(AppConfig.Account.PasswordResetTTLMinutes) * time.Minute
+}
+
+
+func GetMFAChallengeTTL() time.Duration {
+	return time.Duration(AppConfig.MFA.ChallengeTTLSeconds) * time.Second
+}
+
+
+func GetIdempotencyTTL() time.Duration {
+	return time.Duration(AppConfig.Idempotency.TTLSeconds) * time.Second
+}
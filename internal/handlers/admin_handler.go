@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	reconciliationService *services.ReconciliationService
+}
+
+func NewAdminHandler(reconciliationService *services.ReconciliationService) *AdminHandler {
+	return &AdminHandler{reconciliationService: reconciliationService}
+}
+
+// GetReconciliation runs a reconciliation and returns the drift report.
+// Pass ?freeze=true to freeze every drifted wallet.
+func (h *AdminHandler) GetReconciliation(c *gin.Context) {
+	autoFreeze, _ := strconv.ParseBool(c.DefaultQuery("freeze", "false"))
+
+	report, err := h.reconciliationService.Run(autoFreeze)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile balances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// FreezeWallet blocks all money movement through a wallet
+func (h *AdminHandler) FreezeWallet(c *gin.Context) {
+	h.setWalletFrozen(c, true)
+}
+
+// UnfreezeWallet lifts a freeze set manually or by reconciliation
+func (h *AdminHandler) UnfreezeWallet(c *gin.Context) {
+	h.setWalletFrozen(c, false)
+}
+
+func (h *AdminHandler) setWalletFrozen(c *gin.Context, frozen bool) {
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet ID"})
+		return
+	}
+
+	wallet, err := h.reconciliationService.SetWalletFrozen(walletID, frozen)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
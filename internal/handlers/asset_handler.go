@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetHandler struct {
+	assets *services.AssetRegistry
+}
+
+func NewAssetHandler(assets *services.AssetRegistry) *AssetHandler {
+	return &AssetHandler{assets: assets}
+}
+
+// ListAssets returns the enabled assets clients can use
+func (h *AssetHandler) ListAssets(c *gin.Context) {
+	assets := []models.Asset{}
+	for _, asset := range h.assets.List() {
+		if asset.Enabled {
+			assets = append(assets, asset)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assets": assets})
+}
+
+// ListAllAssets returns every asset including disabled ones (admin)
+func (h *AssetHandler) ListAllAssets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"assets": h.assets.List()})
+}
+
+// CreateAsset lists a new asset (admin)
+func (h *AssetHandler) CreateAsset(c *gin.Context) {
+	var req models.AssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	asset, err := h.assets.Create(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, asset)
+}
+
+// UpdateAsset changes an asset's settings (admin)
+func (h *AssetHandler) UpdateAsset(c *gin.Context) {
+	var req models.AssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	asset, err := h.assets.Update(c.Param("symbol"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, asset)
+}
@@ -4,6 +4,9 @@ import (
 	"crypto-wallet-service/internal/middleware"
 	"crypto-wallet-service/internal/models"
 	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/services"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,11 +14,24 @@ import (
 )
 
 type AuthHandler struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	tokenService   *services.TokenService
+	mfaService     *services.MFAService
+	accountService *services.AccountService
 }
 
-func NewAuthHandler(userRepo repository.UserRepository) *AuthHandler {
-	return &AuthHandler{userRepo: userRepo}
+func NewAuthHandler(
+	userRepo repository.UserRepository,
+	tokenService *services.TokenService,
+	mfaService *services.MFAService,
+	accountService *services.AccountService,
+) *AuthHandler {
+	return &AuthHandler{
+		userRepo:       userRepo,
+		tokenService:   tokenService,
+		mfaService:     mfaService,
+		accountService: accountService,
+	}
 }
 
 
@@ -32,9 +48,36 @@ type LoginRequest struct {
 }
 
 
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+
 type AuthResponse struct {
-	Token string              `json:"token"`
-	User  models.UserResponse `json:"user"`
+	models.TokenPair
+	User models.UserResponse `json:"user"`
 }
 
 
@@ -71,16 +114,22 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// The account works without verification, so a failed email only
+	// means the user has to ask for a new one
+	if err := h.accountService.SendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
 
-	token, err := middleware.GenerateToken(user.ID, user.Email)
+	tokens, err := h.tokenService.IssueTokens(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
+		TokenPair: *tokens,
+		User:      user.ToResponse(),
 	})
 }
 
@@ -106,19 +155,186 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 
-	token, err := middleware.GenerateToken(user.ID, user.Email)
+	// With 2FA enabled the password only earns an MFA token, which is
+	// exchanged for tokens at /auth/login/mfa
+	if user.TOTPEnabled {
+		challenge, err := h.mfaService.StartChallenge(c.Request.Context(), user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+
+	tokens, err := h.tokenService.IssueTokens(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
+		TokenPair: *tokens,
+		User:      user.ToResponse(),
 	})
 }
 
 
+// LoginMFA completes a two-factor login with the MFA token from Login and a
+// TOTP or recovery code
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.mfaService.CompleteChallenge(c.Request.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err, http.StatusUnauthorized), gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.tokenService.IssueTokens(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		TokenPair: *tokens,
+		User:      user.ToResponse(),
+	})
+}
+
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// refresh token is rotated and cannot be used again.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+
+// Logout revokes the current session: its refresh tokens and access tokens
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, err := middleware.GetClaimsFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tokenService.Logout(c.Request.Context(), claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+
+// VerifyEmail confirms the email address with the token from the
+// verification email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidAccountToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+
+// ResendVerification mails a new verification link to the signed in user
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ResendVerificationEmail(userID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTooManyAccountEmails):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+
+// ForgotPassword mails a reset link. The response is the same whether or
+// not the email is registered.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ForgotPassword(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+
+// ResetPassword sets a new password with the token from the reset email.
+// All sessions of the user are revoked.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidAccountToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+}
+
+
 func (h *AuthHandler) GetMe(c *gin.Context) {
 	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
@@ -134,3 +350,11 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user.ToResponse())
 }
+
+
+// JWKS publishes the public keys that verify access tokens so other services
+// can check tokens without calling this one
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, middleware.PublicJWKS())
+}
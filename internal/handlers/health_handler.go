@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priceRefresherDegradedAfter is how many failed refreshes in a row mark the
+// service as degraded
+const priceRefresherDegradedAfter = 3
+
+type HealthHandler struct {
+	priceRefresher *services.PriceRefresher
+}
+
+func NewHealthHandler(priceRefresher *services.PriceRefresher) *HealthHandler {
+	return &HealthHandler{priceRefresher: priceRefresher}
+}
+
+// Health reports whether the service is up together with the status of the
+// background price refresher. A failing refresher only degrades the status;
+// the endpoint stays 200 because stale prices are still served.
+func (h *HealthHandler) Health(c *gin.Context) {
+	refresher := h.priceRefresher.Status()
+
+	status := "ok"
+	if refresher.ConsecutiveFailures >= priceRefresherDegradedAfter {
+		status = "degraded"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":          status,
+		"price_refresher": refresher,
+	})
+}
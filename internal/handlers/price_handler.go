@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/services"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PriceHandler struct {
+	priceService *services.PriceService
+	assets       *services.AssetRegistry
+}
+
+func NewPriceHandler(priceService *services.PriceService, assets *services.AssetRegistry) *PriceHandler {
+	return &PriceHandler{
+		priceService: priceService,
+		assets:       assets,
+	}
+}
+
+// GetHistory returns the recorded IDR prices of an asset, one per interval
+func (h *PriceHandler) GetHistory(c *gin.Context) {
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if asset, ok := h.assets.Get(symbol); !ok || !asset.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset not found"})
+		return
+	}
+
+	var err error
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		if to, err = parseHistoryTime(value, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if value := c.Query("from"); value != "" {
+		if from, err = parseHistoryTime(value, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	history, err := h.priceService.PriceHistory(symbol, from, to, c.DefaultQuery("interval", "1d"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidHistoryRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
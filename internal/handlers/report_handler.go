@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/services"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	taxReportService *services.TaxReportService
+}
+
+func NewReportHandler(taxReportService *services.TaxReportService) *ReportHandler {
+	return &ReportHandler{taxReportService: taxReportService}
+}
+
+// GetTaxReport returns the annual tax report as JSON, or as CSV with
+// ?format=csv. The CSV contains one section chosen with ?section=
+// (disposals, monthly or assets).
+func (h *ReportHandler) GetTaxReport(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	year := time.Now().Year()
+	if value := c.Query("year"); value != "" {
+		year, err = strconv.Atoi(value)
+		if err != nil || year < 2000 || year > time.Now().Year() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return
+		}
+	}
+
+	method, err := models.ParseCostMethod(c.Query("method"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.taxReportService.Generate(userID, year, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tax report"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		writeTaxReportCSV(c, report, c.DefaultQuery("section", "disposals"))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
+func writeTaxReportCSV(c *gin.Context, report *models.TaxReport, section string) {
+	var rows [][]string
+	switch section {
+	case "disposals":
+		rows = append(rows, []string{"transaction_id", "date", "type", "currency", "amount", "price_idr", "value_idr", "cost_basis_idr", "gain_idr", "pph_idr", "ppn_idr", "price_missing"})
+		for _, d := range report.Disposals {
+			rows = append(rows, []string{
+				d.TransactionID.String(),
+				d.Date.Format(time.RFC3339),
+				string(d.Type),
+				d.Currency,
+				d.Amount.String(),
+				d.PriceIDR.String(),
+				d.ValueIDR.String(),
+				d.CostBasisIDR.String(),
+				d.GainIDR.String(),
+				d.PPhIDR.String(),
+				d.PPNIDR.String(),
+				strconv.FormatBool(d.PriceMissing),
+			})
+		}
+	case "monthly", "assets":
+		summaries := report.Monthly
+		if section == "assets" {
+			summaries = report.PerAsset
+		}
+
+		rows = append(rows, []string{"key", "disposals", "value_idr", "gain_idr", "pph_idr", "ppn_idr", "tax_idr"})
+		for _, s := range append(summaries, report.Total) {
+			rows = append(rows, []string{
+				s.Key,
+				strconv.Itoa(s.Disposals),
+				s.ValueIDR.String(),
+				s.GainIDR.String(),
+				s.PPhIDR.String(),
+				s.PPNIDR.String(),
+				s.TaxIDR.String(),
+			})
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "section must be disposals, monthly or assets"})
+		return
+	}
+
+	filename := fmt.Sprintf("tax-report-%d-%s.csv", report.Year, section)
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.WriteAll(rows)
+}
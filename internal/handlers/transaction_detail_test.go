@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func (r *fakeTransactionRepo) FindByID(id uuid.UUID) (*models.Transaction, error) {
+	for _, tx := range r.rows {
+		if tx.ID == id {
+			return &tx, nil
+		}
+	}
+	return nil, repository.ErrTransactionNotFound
+}
+
+func (r *fakeTransactionRepo) FindByReferenceID(userID uuid.UUID, referenceID uuid.UUID) ([]models.Transaction, error) {
+	var linked []models.Transaction
+	for _, tx := range r.rows {
+		if tx.UserID == userID && tx.ReferenceID != nil && *tx.ReferenceID == referenceID {
+			linked = append(linked, tx)
+		}
+	}
+	return linked, nil
+}
+
+func (r *fakeTransactionRepo) BalanceAfter(transaction *models.Transaction) (decimal.Decimal, error) {
+	balance := decimal.Zero
+	for _, tx := range r.rows {
+		if tx.UserID == transaction.UserID && tx.Currency == transaction.Currency && !tx.CreatedAt.After(transaction.CreatedAt) {
+			balance = balance.Add(tx.SignedAmount())
+		}
+	}
+	return balance, nil
+}
+
+func getTransaction(t *testing.T, repo *fakeTransactionRepo, userID uuid.UUID, id string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(repo, services.NewTransactionService(repo, nil), nil)
+	router := gin.New()
+	router.GET("/transactions/:id", func(c *gin.Context) {
+		c.Set("user_id", userID)
+	}, handler.GetTransaction)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/transactions/"+id, nil))
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return w, body
+}
+
+func TestGetTransactionDetail(t *testing.T) {
+	owner := uuid.New()
+	reference := uuid.New()
+	at := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+
+	deposit := models.Transaction{ID: uuid.New(), UserID: owner, Type: models.TransactionTypeDeposit, Currency: "BTC",
+		Amount: decimal.NewFromInt(2), PriceAt: decimal.NewFromInt(1000), CreatedAt: at}
+	withdraw := models.Transaction{ID: uuid.New(), UserID: owner, Type: models.TransactionTypeWithdraw, Currency: "BTC",
+		Amount: decimal.RequireFromString("0.5"), PriceAt: decimal.NewFromInt(1200), ReferenceID: &reference, CreatedAt: at.Add(time.Hour)}
+	fee := models.Transaction{ID: uuid.New(), UserID: owner, Type: models.TransactionTypeFee, Currency: "BTC",
+		Amount: decimal.RequireFromString("0.01"), PriceAt: decimal.NewFromInt(1200), ReferenceID: &reference, CreatedAt: at.Add(time.Hour)}
+	repo := &fakeTransactionRepo{rows: []models.Transaction{deposit, withdraw, fee}}
+
+	w, body := getTransaction(t, repo, owner, withdraw.ID.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %v", w.Code, http.StatusOK, body)
+	}
+	if body["value_idr"] != "600" || body["balance_after"] != "1.49" {
+		t.Errorf("value_idr = %v, balance_after = %v, want 600 and 1.49", body["value_idr"], body["balance_after"])
+	}
+	related, _ := body["related"].([]any)
+	if len(related) != 1 || related[0].(map[string]any)["id"] != fee.ID.String() {
+		t.Errorf("related = %v, want only the fee entry", related)
+	}
+}
+
+func TestGetTransactionHidesOtherUsersTransactions(t *testing.T) {
+	owner := uuid.New()
+	tx := models.Transaction{ID: uuid.New(), UserID: owner, Type: models.TransactionTypeDeposit, Currency: "IDR",
+		Amount: decimal.NewFromInt(100), CreatedAt: time.Now()}
+	repo := &fakeTransactionRepo{rows: []models.Transaction{tx}}
+
+	// Another user's transaction looks exactly like one that does not exist
+	foreign, foreignBody := getTransaction(t, repo, uuid.New(), tx.ID.String())
+	missing, missingBody := getTransaction(t, repo, owner, uuid.NewString())
+	for name, w := range map[string]*httptest.ResponseRecorder{"other user": foreign, "unknown id": missing} {
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+	if foreignBody["error"] != missingBody["error"] {
+		t.Errorf("errors differ: %v vs %v", foreignBody["error"], missingBody["error"])
+	}
+
+	if w, _ := getTransaction(t, repo, owner, "not-a-uuid"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid id: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
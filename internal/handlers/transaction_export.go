@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// exportFlushEvery controls how often buffered export rows are sent to the client
+const exportFlushEvery = 100
+
+// exportRow is a transaction with the values computed during export
+type exportRow struct {
+	models.Transaction
+	SignedAmount decimal.Decimal
+	ValueIDR     decimal.Decimal
+	BalanceAfter decimal.Decimal
+}
+
+type exportWriter interface {
+	Begin() error
+	Write(row exportRow) error
+	End() error
+}
+
+// balanceTracker is implemented by writers that need every row that moved a
+// balance, including the rows the filter hides
+type balanceTracker interface {
+	Track(row exportRow)
+}
+
+var exportContentTypes = map[string]string{
+	"csv":   "text/csv",
+	"jsonl": "application/x-ndjson",
+	"ofx":   "application/x-ofx",
+}
+
+// ExportTransactions streams the user's full transaction history as CSV,
+// JSON Lines or OFX. It accepts the same filters as GetTransactions. Rows are
+// grouped per currency, oldest first, with the running balance after each
+// transaction. Filtered-out rows still count towards the running balance.
+func (h *TransactionHandler) ExportTransactions(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, jsonl or ofx"})
+		return
+	}
+
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := query.Filter
+
+	// The running balance needs every row, so only currency and date are
+	// filtered in the database
+	streamFilter := models.TransactionFilter{
+		Currencies: filter.Currencies,
+		From:       filter.From,
+		To:         filter.To,
+	}
+
+	balances := map[string]decimal.Decimal{}
+	if filter.From != nil {
+		opening, err := h.transactionRepo.SumUserBalancesBefore(userID, *filter.From)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute opening balances"})
+			return
+		}
+		for _, b := range opening {
+			balances[b.Currency] = b.Balance
+		}
+	}
+
+	var writer exportWriter
+	switch format {
+	case "csv":
+		writer = &csvExportWriter{w: csv.NewWriter(c.Writer)}
+	case "jsonl":
+		writer = &jsonlExportWriter{enc: json.NewEncoder(c.Writer)}
+	case "ofx":
+		writer = newOFXExportWriter(c.Writer, userID, filter)
+	}
+
+	filename := fmt.Sprintf("transactions-%s.%s", time.Now().UTC().Format("20060102"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if err := writer.Begin(); err != nil {
+		log.Printf("Transaction export failed: %v", err)
+		return
+	}
+
+	written := 0
+	err = h.transactionRepo.StreamByUserID(userID, streamFilter, func(tx models.Transaction) error {
+		signed := tx.SignedAmount()
+		if tx.AffectsBalance() {
+			balances[tx.Currency] = balances[tx.Currency].Add(signed)
+		}
+
+		row := exportRow{
+			Transaction:  tx,
+			SignedAmount: signed,
+			ValueIDR:     tx.Amount.Mul(tx.PriceAt).Round(2),
+			BalanceAfter: balances[tx.Currency],
+		}
+		if tracker, ok := writer.(balanceTracker); ok && tx.AffectsBalance() {
+			tracker.Track(row)
+		}
+
+		if !matchesExportFilter(tx, filter) {
+			return nil
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			if flusher, ok := writer.(interface{ Flush() }); ok {
+				flusher.Flush()
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent, so the client only sees a truncated file
+		log.Printf("Transaction export failed after %d rows: %v", written, err)
+		return
+	}
+
+	if err := writer.End(); err != nil {
+		log.Printf("Transaction export failed: %v", err)
+	}
+	c.Writer.Flush()
+}
+
+func matchesExportFilter(tx models.Transaction, filter models.TransactionFilter) bool {
+	if len(filter.Types) > 0 {
+		found := false
+		for _, t := range filter.Types {
+			if tx.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.MinAmount != nil && tx.Amount.LessThan(*filter.MinAmount) {
+		return false
+	}
+	if filter.MaxAmount != nil && tx.Amount.GreaterThan(*filter.MaxAmount) {
+		return false
+	}
+	return true
+}
+
+func optionalID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) Begin() error {
+	return e.w.Write([]string{
+		"id", "created_at", "type", "direction", "currency", "amount", "signed_amount",
+		"price_idr", "value_idr", "balance_after", "counterparty_id", "reference_id", "status",
+	})
+}
+
+func (e *csvExportWriter) Write(row exportRow) error {
+	direction := string(row.Direction)
+	if direction == "" {
+		direction = string(models.TransactionDirectionDebit)
+		if row.SignedAmount.IsPositive() {
+			direction = string(models.TransactionDirectionCredit)
+		}
+	}
+
+	return e.w.Write([]string{
+		row.ID.String(),
+		row.CreatedAt.UTC().Format(time.RFC3339),
+		string(row.Type),
+		direction,
+		row.Currency,
+		row.Amount.String(),
+		row.SignedAmount.String(),
+		row.PriceAt.String(),
+		row.ValueIDR.String(),
+		row.BalanceAfter.String(),
+		optionalID(row.CounterpartyID),
+		optionalID(row.ReferenceID),
+		string(row.Status),
+	})
+}
+
+func (e *csvExportWriter) Flush() {
+	e.w.Flush()
+}
+
+func (e *csvExportWriter) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlExportWriter struct {
+	enc *json.Encoder
+}
+
+type jsonlExportRecord struct {
+	models.Transaction
+	SignedAmount decimal.Decimal `json:"signed_amount"`
+	ValueIDR     decimal.Decimal `json:"value_idr"`
+	BalanceAfter decimal.Decimal `json:"balance_after"`
+	User         *struct{}       `json:"user,omitempty"` // hide the embedded User relation
+}
+
+func (e *jsonlExportWriter) Begin() error {
+	return nil
+}
+
+func (e *jsonlExportWriter) Write(row exportRow) error {
+	return e.enc.Encode(jsonlExportRecord{
+		Transaction:  row.Transaction,
+		SignedAmount: row.SignedAmount,
+		ValueIDR:     row.ValueIDR,
+		BalanceAfter: row.BalanceAfter,
+	})
+}
+
+func (e *jsonlExportWriter) End() error {
+	return nil
+}
+
+// ofxExportWriter writes an OFX 2.1.1 bank statement per currency. Amounts
+// are the IDR value of each transaction; the crypto amount and running
+// balance are kept in the memo.
+type ofxExportWriter struct {
+	w        io.Writer
+	userID   uuid.UUID
+	end      time.Time
+	currency string
+	balances map[string]decimal.Decimal // balance after the last tracked row
+	prices   map[string]decimal.Decimal // last known price
+	err      error
+}
+
+func newOFXExportWriter(w io.Writer, userID uuid.UUID, filter models.TransactionFilter) *ofxExportWriter {
+	end := time.Now().UTC()
+	if filter.To != nil && filter.To.Before(end) {
+		end = *filter.To
+	}
+	return &ofxExportWriter{
+		w:        w,
+		userID:   userID,
+		end:      end,
+		balances: map[string]decimal.Decimal{},
+		prices:   map[string]decimal.Decimal{},
+	}
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + ".000[0:UTC]"
+}
+
+func ofxText(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func (e *ofxExportWriter) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+func (e *ofxExportWriter) Begin() error {
+	e.printf(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	e.printf(`<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	e.printf("<OFX>\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	e.printf("<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", ofxTime(time.Now()))
+	e.printf("<BANKMSGSRSV1>\n")
+	return e.err
+}
+
+// Track follows the closing balance through every row that moved the wallet,
+// so rows hidden by the filter still count towards LEDGERBAL
+func (e *ofxExportWriter) Track(row exportRow) {
+	e.balances[row.Currency] = row.BalanceAfter
+	if row.PriceAt.IsPositive() {
+		e.prices[row.Currency] = row.PriceAt
+	}
+}
+
+func (e *ofxExportWriter) Write(row exportRow) error {
+	// An OFX statement lists movements of the account; imported history
+	// never moved the wallet
+	if !row.AffectsBalance() {
+		return e.err
+	}
+
+	if row.Currency != e.currency {
+		e.closeStatement()
+		e.openStatement(row.Currency, row.CreatedAt)
+	}
+
+	trnType := "DEBIT"
+	switch {
+	case row.Type == models.TransactionTypeFee:
+		trnType = "FEE"
+	case row.SignedAmount.IsPositive():
+		trnType = "CREDIT"
+	}
+
+	value := row.ValueIDR
+	if row.SignedAmount.IsNegative() {
+		value = value.Neg()
+	}
+
+	memo := fmt.Sprintf("%s %s @ %s IDR, balance %s %s",
+		row.SignedAmount.String(), row.Currency, row.PriceAt.String(), row.BalanceAfter.String(), row.Currency)
+
+	e.printf("<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+		trnType, ofxTime(row.CreatedAt), value.StringFixed(2), row.ID, ofxText(string(row.Type)+" "+row.Currency), ofxText(memo))
+	return e.err
+}
+
+func (e *ofxExportWriter) End() error {
+	e.closeStatement()
+	e.printf("</BANKMSGSRSV1>\n</OFX>\n")
+	return e.err
+}
+
+func (e *ofxExportWriter) openStatement(currency string, start time.Time) {
+	e.currency = currency
+
+	e.printf("<STMTTRNRS><TRNUID>%s</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", ofxText(currency))
+	e.printf("<STMTRS><CURDEF>IDR</CURDEF>")
+	e.printf("<BANKACCTFROM><BANKID>CRYPTOWALLET</BANKID><ACCTID>%s-%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", e.userID, ofxText(currency))
+	e.printf("<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxTime(start), ofxTime(e.end))
+}
+
+// closeStatement ends the open statement with the closing balance valued at
+// the last known price. Rows are grouped per currency, so every row of the
+// currency has been tracked by the time its statement is closed.
+func (e *ofxExportWriter) closeStatement() {
+	if e.currency == "" {
+		return
+	}
+	closing := e.balances[e.currency].Mul(e.prices[e.currency])
+	e.printf("</BANKTRANLIST><LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL></STMTRS></STMTTRNRS>\n",
+		closing.StringFixed(2), ofxTime(e.end))
+	e.currency = ""
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto-wallet-service/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// StreamByUserID applies the database part of the filter to the fixed rows,
+// which must already be ordered by currency and time
+func (r *fakeTransactionRepo) StreamByUserID(userID uuid.UUID, filter models.TransactionFilter, fn func(models.Transaction) error) error {
+	for _, tx := range r.rows {
+		if len(filter.Currencies) > 0 && !containsString(filter.Currencies, tx.Currency) {
+			continue
+		}
+		if filter.From != nil && tx.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !tx.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeTransactionRepo) SumUserBalancesBefore(userID uuid.UUID, before time.Time) ([]models.ComputedBalance, error) {
+	r.openingBefore = &before
+	return r.opening, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var exportStart = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func exportTx(currency string, txType models.TransactionType, direction models.TransactionDirection, amount, price string, day int) models.Transaction {
+	return models.Transaction{
+		ID:        uuid.New(),
+		Type:      txType,
+		Direction: direction,
+		Currency:  currency,
+		Amount:    decimal.RequireFromString(amount),
+		PriceAt:   decimal.RequireFromString(price),
+		Status:    models.TransactionStatusCompleted,
+		CreatedAt: exportStart.AddDate(0, 0, day),
+	}
+}
+
+// newExportRepo holds a BTC history with an imported row in between, followed
+// by one ETH deposit
+func newExportRepo() *fakeTransactionRepo {
+	imported := exportTx("BTC", models.TransactionTypeDeposit, models.TransactionDirectionCredit, "5", "110", 2)
+	imported.Status = models.TransactionStatusImported
+
+	return &fakeTransactionRepo{rows: []models.Transaction{
+		exportTx("BTC", models.TransactionTypeDeposit, models.TransactionDirectionCredit, "1", "100", 0),
+		exportTx("BTC", models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "0.25", "120", 1),
+		imported,
+		exportTx("BTC", models.TransactionTypeFee, models.TransactionDirectionDebit, "0.05", "130", 3),
+		exportTx("ETH", models.TransactionTypeDeposit, models.TransactionDirectionCredit, "2", "10", 4),
+	}}
+}
+
+func exportTransactions(t *testing.T, repo *fakeTransactionRepo, query string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(repo, nil, nil)
+	router := gin.New()
+	router.GET("/transactions/export", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+	}, handler.ExportTransactions)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/transactions/export?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	return w.Body.String()
+}
+
+// csvColumn returns one column of every data row of a CSV export
+func csvColumn(t *testing.T, body, column string) []string {
+	t.Helper()
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := -1
+	for i, name := range records[0] {
+		if name == column {
+			index = i
+		}
+	}
+	if index < 0 {
+		t.Fatalf("no %s column in %v", column, records[0])
+	}
+
+	var values []string
+	for _, record := range records[1:] {
+		values = append(values, record[index])
+	}
+	return values
+}
+
+func assertColumn(t *testing.T, name string, got, want []string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestExportCSVRunningBalance(t *testing.T) {
+	body := exportTransactions(t, newExportRepo(), "format=csv")
+
+	assertColumn(t, "type", csvColumn(t, body, "type"), []string{"deposit", "withdraw", "deposit", "fee", "deposit"})
+	assertColumn(t, "signed_amount", csvColumn(t, body, "signed_amount"), []string{"1", "-0.25", "5", "-0.05", "2"})
+	// The imported row is listed but leaves the balance alone
+	assertColumn(t, "balance_after", csvColumn(t, body, "balance_after"), []string{"1", "0.75", "0.75", "0.7", "2"})
+	assertColumn(t, "status", csvColumn(t, body, "status"), []string{"completed", "completed", "imported", "completed", "completed"})
+	assertColumn(t, "value_idr", csvColumn(t, body, "value_idr"), []string{"100", "30", "550", "6.5", "20"})
+}
+
+func TestExportJSONLines(t *testing.T) {
+	repo := newExportRepo()
+	body := exportTransactions(t, repo, "format=jsonl&currency=BTC")
+
+	var balances []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		if _, ok := record["user"]; ok {
+			t.Error("record exposes the user relation")
+		}
+		if record["currency"] != "BTC" {
+			t.Errorf("currency = %v, want only BTC", record["currency"])
+		}
+		balances = append(balances, record["balance_after"].(string))
+	}
+	assertColumn(t, "balance_after", balances, []string{"1", "0.75", "0.75", "0.7"})
+}
+
+func TestExportStartsFromOpeningBalance(t *testing.T) {
+	repo := newExportRepo()
+	repo.opening = []models.ComputedBalance{{Currency: "BTC", Balance: decimal.NewFromInt(3)}}
+	from := exportStart.AddDate(0, 0, 1)
+
+	body := exportTransactions(t, repo, "format=csv&from="+from.Format(time.RFC3339))
+
+	if repo.openingBefore == nil || !repo.openingBefore.Equal(from) {
+		t.Errorf("opening balance computed before %v, want %v", repo.openingBefore, from)
+	}
+	assertColumn(t, "balance_after", csvColumn(t, body, "balance_after"), []string{"2.75", "2.75", "2.7", "2"})
+}
+
+func TestExportFilteredRowsKeepRunningBalance(t *testing.T) {
+	body := exportTransactions(t, newExportRepo(), "format=csv&type=withdraw,fee")
+
+	assertColumn(t, "type", csvColumn(t, body, "type"), []string{"withdraw", "fee"})
+	// The hidden deposit still counts
+	assertColumn(t, "balance_after", csvColumn(t, body, "balance_after"), []string{"0.75", "0.7"})
+}
+
+func TestExportOFXClosingBalanceIncludesHiddenRows(t *testing.T) {
+	body := exportTransactions(t, newExportRepo(), "format=ofx&type=deposit")
+
+	// Only the BTC and ETH deposits are listed; the imported row never moved
+	// the wallet
+	if n := strings.Count(body, "<STMTTRN>"); n != 2 {
+		t.Errorf("%d transactions in the OFX, want 2", n)
+	}
+
+	// The closing balance covers the hidden withdrawal and fee and is valued
+	// at the last price seen: 0.7 BTC at 130 and 2 ETH at 10
+	balances := regexp.MustCompile(`<LEDGERBAL><BALAMT>([^<]*)</BALAMT>`).FindAllStringSubmatch(body, -1)
+	var got []string
+	for _, b := range balances {
+		got = append(got, b[1])
+	}
+	assertColumn(t, "LEDGERBAL", got, []string{"91.00", "20.00"})
+}
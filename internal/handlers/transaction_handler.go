@@ -2,22 +2,43 @@ package handlers
 
 import (
 	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
 	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/services"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type TransactionHandler struct {
-	transactionRepo repository.TransactionRepository
+	transactionRepo    repository.TransactionRepository
+	transactionService *services.TransactionService
+	importService      *services.ImportService
 }
 
-func NewTransactionHandler(transactionRepo repository.TransactionRepository) *TransactionHandler {
-	return &TransactionHandler{transactionRepo: transactionRepo}
+func NewTransactionHandler(
+	transactionRepo repository.TransactionRepository,
+	transactionService *services.TransactionService,
+	importService *services.ImportService,
+) *TransactionHandler {
+	return &TransactionHandler{
+		transactionRepo:    transactionRepo,
+		transactionService: transactionService,
+		importService:      importService,
+	}
 }
 
 
+// GetTransactions returns the user's transaction history. Without a cursor
+// it keeps the original page/limit pagination; passing the next_cursor of a
+// previous response switches to keyset pagination, which stays consistent
+// while new transactions arrive.
 func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 	userID, err := middleware.GetUserIDFromContext(c)
 	if err != nil {
@@ -36,29 +57,166 @@ func (h *TransactionHandler) GetTransactions(c *gin.Context) {
 		page = 1
 	}
 
-	offset := (page - 1) * limit
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Limit = limit + 1 // one extra row tells whether there is a next page
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := models.DecodeTransactionCursor(value)
+		if err != nil || cursor.SortBy != query.SortBy || cursor.Desc != query.Desc {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor for this sort order"})
+			return
+		}
+		query.Cursor = cursor
+	} else {
+		query.Offset = (page - 1) * limit
+	}
 
 
-	transactions, err := h.transactionRepo.FindByUserID(userID, limit, offset)
+	transactions, err := h.transactionRepo.Search(userID, query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
 		return
 	}
 
+	hasMore := len(transactions) > limit
+	if hasMore {
+		transactions = transactions[:limit]
+	}
 
-	total, err := h.transactionRepo.CountByUserID(userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count transactions"})
-		return
+	pagination := gin.H{
+		"limit":    limit,
+		"has_more": hasMore,
+	}
+	if hasMore {
+		last := transactions[len(transactions)-1]
+		pagination["next_cursor"] = models.NewTransactionCursor(last, query.SortBy, query.Desc).Encode()
+	}
+
+	if query.Cursor == nil {
+		total, err := h.transactionRepo.CountFiltered(userID, query.Filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count transactions"})
+			return
+		}
+
+		pagination["total"] = total
+		pagination["page"] = page
+		pagination["total_pages"] = (total + int64(limit) - 1) / int64(limit)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"transactions": transactions,
-		"pagination": gin.H{
-			"total":       total,
-			"page":        page,
-			"limit":       limit,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-		},
+		"pagination":   pagination,
 	})
 }
+
+
+// GetTransaction returns a single transaction of the authenticated user.
+// Transactions of other users are reported as 404, never 403.
+func (h *TransactionHandler) GetTransaction(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	detail, err := h.transactionService.GetDetail(userID, transactionID)
+	if err != nil {
+		if errors.Is(err, services.ErrTransactionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+
+// parseTransactionQuery reads the filter and sort parameters shared by the
+// history and export endpoints:
+// type, currency (comma separated), from, to, min_amount, max_amount,
+// sort (created_at or amount) and order (asc or desc)
+func parseTransactionQuery(c *gin.Context) (models.TransactionQuery, error) {
+	var query models.TransactionQuery
+	filter := &query.Filter
+
+	for _, value := range splitQueryList(c.Query("type")) {
+		txType := models.TransactionType(strings.ToLower(value))
+		switch txType {
+		case models.TransactionTypeDeposit, models.TransactionTypeWithdraw, models.TransactionTypeTransfer, models.TransactionTypeFee:
+			filter.Types = append(filter.Types, txType)
+		default:
+			return query, fmt.Errorf("invalid transaction type %q", value)
+		}
+	}
+
+	for _, value := range splitQueryList(c.Query("currency")) {
+		filter.Currencies = append(filter.Currencies, strings.ToUpper(value))
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseHistoryTime(value, false)
+		if err != nil {
+			return query, err
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseHistoryTime(value, true)
+		if err != nil {
+			return query, err
+		}
+		filter.To = &to
+	}
+
+	for name, target := range map[string]**decimal.Decimal{
+		"min_amount": &filter.MinAmount,
+		"max_amount": &filter.MaxAmount,
+	} {
+		if value := c.Query(name); value != "" {
+			amount, err := decimal.NewFromString(value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s", name)
+			}
+			*target = &amount
+		}
+	}
+
+	query.SortBy = c.DefaultQuery("sort", models.TransactionSortCreatedAt)
+	if query.SortBy != models.TransactionSortCreatedAt && query.SortBy != models.TransactionSortAmount {
+		return query, fmt.Errorf("sort must be %s or %s", models.TransactionSortCreatedAt, models.TransactionSortAmount)
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+		query.Desc = true
+	case "asc":
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	return query, nil
+}
+
+
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// fakeTransactionRepo returns fixed rows from Search and StreamByUserID and
+// records the queries it was called with. Only the methods used by
+// GetTransactions and ExportTransactions are implemented.
+type fakeTransactionRepo struct {
+	repository.TransactionRepository
+	rows    []models.Transaction
+	queries []models.TransactionQuery
+
+	opening       []models.ComputedBalance // returned by SumUserBalancesBefore
+	openingBefore *time.Time
+}
+
+func (r *fakeTransactionRepo) Search(userID uuid.UUID, query models.TransactionQuery) ([]models.Transaction, error) {
+	r.queries = append(r.queries, query)
+	rows := r.rows
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+	}
+	return rows, nil
+}
+
+func (r *fakeTransactionRepo) CountFiltered(userID uuid.UUID, filter models.TransactionFilter) (int64, error) {
+	return int64(len(r.rows)), nil
+}
+
+func getTransactions(t *testing.T, repo *fakeTransactionRepo, query string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	handler := NewTransactionHandler(repo, nil, nil)
+	router := gin.New()
+	router.GET("/transactions", func(c *gin.Context) {
+		c.Set("user_id", uuid.New())
+	}, handler.GetTransactions)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/transactions?"+query, nil))
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return w, body
+}
+
+func TestGetTransactionsNextCursorResumesAfterLastRow(t *testing.T) {
+	createdAt := time.Date(2025, 3, 10, 2, 30, 0, 0, time.UTC)
+	repo := &fakeTransactionRepo{}
+	for i := 0; i < 3; i++ {
+		repo.rows = append(repo.rows, models.Transaction{
+			ID:        uuid.New(),
+			Amount:    decimal.NewFromInt(int64(100 - i)),
+			CreatedAt: createdAt,
+		})
+	}
+
+	w, body := getTransactions(t, repo, "limit=2&sort=amount&order=desc")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	pagination := body["pagination"].(map[string]any)
+	next, _ := pagination["next_cursor"].(string)
+	if pagination["has_more"] != true || next == "" {
+		t.Fatalf("pagination = %v, want has_more with a next_cursor", pagination)
+	}
+
+	w, _ = getTransactions(t, repo, "limit=2&sort=amount&order=desc&cursor="+next)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status with cursor = %d, want %d", w.Code, http.StatusOK)
+	}
+	cursor := repo.queries[1].Cursor
+	if cursor == nil {
+		t.Fatal("the cursor was not passed to the repository")
+	}
+	if cursor.ID != repo.rows[1].ID || cursor.Value != "99" {
+		t.Errorf("cursor = %+v, want the second row (id %s, amount 99)", *cursor, repo.rows[1].ID)
+	}
+	if repo.queries[1].Offset != 0 {
+		t.Errorf("offset = %d, want 0 with a cursor", repo.queries[1].Offset)
+	}
+}
+
+func TestGetTransactionsRejectsCursorFromAnotherSort(t *testing.T) {
+	row := models.Transaction{ID: uuid.New(), Amount: decimal.NewFromInt(5), CreatedAt: time.Now()}
+	cursor := models.NewTransactionCursor(row, models.TransactionSortCreatedAt, true).Encode()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"same sort", "sort=created_at&order=desc", http.StatusOK},
+		{"default sort is created_at desc", "", http.StatusOK},
+		{"other sort column", "sort=amount&order=desc", http.StatusBadRequest},
+		{"other direction", "sort=created_at&order=asc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		repo := &fakeTransactionRepo{}
+		w, body := getTransactions(t, repo, tt.query+"&cursor="+cursor)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if tt.status == http.StatusBadRequest {
+			if body["error"] != "Invalid cursor for this sort order" {
+				t.Errorf("%s: error = %v", tt.name, body["error"])
+			}
+			if len(repo.queries) != 0 {
+				t.Errorf("%s: the repository was queried with a mismatched cursor", tt.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize bounds the uploaded CSV
+const maxImportFileSize = 5 << 20
+
+// ImportTransactions imports a CSV exported from another exchange. The
+// multipart form takes the file, a built-in format name or a custom mapping
+// (JSON), and mode=dry_run (default) to preview or mode=commit to write.
+func (h *TransactionHandler) ImportTransactions(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required in field 'file'"})
+		return
+	}
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "CSV file must be at most 5 MB"})
+		return
+	}
+
+	var mapping models.ImportMapping
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping: " + err.Error()})
+			return
+		}
+	} else {
+		var ok bool
+		mapping, ok = services.ImportFormat(c.DefaultPostForm("format", "generic"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown import format"})
+			return
+		}
+	}
+
+	var dryRun bool
+	switch c.DefaultPostForm("mode", "dry_run") {
+	case "dry_run":
+		dryRun = true
+	case "commit":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be dry_run or commit"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	result, err := h.importService.Import(userID, file, mapping, dryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrImportInvalid):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "result": result})
+		case errors.Is(err, services.ErrInvalidImportFile):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import transactions"})
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if !dryRun {
+		status = http.StatusCreated
+	}
+	c.JSON(status, result)
+}
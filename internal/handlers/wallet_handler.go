@@ -4,7 +4,10 @@ import (
 	"crypto-wallet-service/internal/middleware"
 	"crypto-wallet-service/internal/models"
 	"crypto-wallet-service/internal/services"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,9 +28,15 @@ func (h *WalletHandler) GetWallet(c *gin.Context) {
 		return
 	}
 
-	portfolio, err := h.walletService.GetPortfolio(userID)
+	method, err := models.ParseCostMethod(c.Query("method"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	portfolio, err := h.walletService.GetPortfolio(userID, method)
+	if err != nil {
+		c.JSON(priceErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -49,16 +58,8 @@ func (h *WalletHandler) Deposit(c *gin.Context) {
 	}
 
 
-	validCurrencies := map[string]bool{
-		"BTC": true, "ETH": true, "USDT": true, "IDR": true,
-	}
-	if !validCurrencies[req.Currency] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency. Supported: BTC, ETH, USDT, IDR"})
-		return
-	}
-
 	if err := h.walletService.Deposit(userID, req.Currency, req.Amount); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(priceErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -83,23 +84,148 @@ func (h *WalletHandler) Withdraw(c *gin.Context) {
 		return
 	}
 
-	
-	validCurrencies := map[string]bool{
-		"BTC": true, "ETH": true, "USDT": true, "IDR": true,
+	fee, err := h.walletService.Withdraw(userID, req.Currency, req.Amount, req.TOTPCode)
+	if err != nil {
+		respondWithdrawalError(c, err)
+		return
 	}
-	if !validCurrencies[req.Currency] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency. Supported: BTC, ETH, USDT, IDR"})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Withdrawal successful",
+		"currency": req.Currency,
+		"amount":   req.Amount,
+		"fee":      fee,
+	})
+}
+
+
+// Transfer moves funds from the authenticated user to another user
+func (h *WalletHandler) Transfer(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.walletService.Withdraw(userID, req.Currency, req.Amount); err != nil {
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	transaction, err := h.walletService.Transfer(userID, req.Recipient, req.Currency, req.Amount, req.TOTPCode)
+	if err != nil {
+		respondWithdrawalError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Withdrawal successful",
-		"currency": req.Currency,
-		"amount":   req.Amount,
+		"message":      "Transfer successful",
+		"currency":     req.Currency,
+		"amount":       req.Amount,
+		"recipient_id": transaction.CounterpartyID,
+		"reference_id": transaction.ReferenceID,
 	})
 }
+
+
+// GetPnL returns cost basis and realized/unrealized P&L per currency
+func (h *WalletHandler) GetPnL(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	method, err := models.ParseCostMethod(c.Query("method"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pnl, err := h.walletService.GetPnL(userID, method)
+	if err != nil {
+		c.JSON(priceErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pnl)
+}
+
+
+// GetHistory returns the portfolio value over time, one point per interval
+func (h *WalletHandler) GetHistory(c *gin.Context) {
+	userID, err := middleware.GetUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		if to, err = parseHistoryTime(value, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if value := c.Query("from"); value != "" {
+		if from, err = parseHistoryTime(value, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	history, err := h.walletService.GetPortfolioHistory(userID, from, to, c.DefaultQuery("interval", "1d"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidHistoryRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build portfolio history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+
+// parseHistoryTime accepts a date (2006-01-02) or an RFC 3339 timestamp. A
+// date used as the end of a range includes that whole day.
+func parseHistoryTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC 3339", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+
+// priceErrorStatus reports a price that is older than the allowed staleness
+// as 503 so clients know to retry later
+func priceErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrPriceUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return fallback
+}
+
+
+// respondWithdrawalError tells the client when a withdrawal or transfer needs
+// a two-factor code so it can prompt for one and retry
+func respondWithdrawalError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrMFARequired) || errors.Is(err, services.ErrMFAEnrollmentRequired) ||
+		errors.Is(err, services.ErrInvalidMFACode) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "mfa_required": true})
+		return
+	}
+	c.JSON(mfaErrorStatus(err, priceErrorStatus(err, http.StatusBadRequest)), gin.H{"error": err.Error()})
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT
+const APIKeyPrefix = "cwk_"
+
+// apiKeyTouchInterval limits how often last_used_at is written per key
+const apiKeyTouchInterval = time.Minute
+
+var apiKeyRepo repository.APIKeyRepository
+
+// UseAPIKeys enables API key authentication on routes that declare scopes
+// and prepares the encryption of request signing secrets
+func UseAPIKeys(repo repository.APIKeyRepository) error {
+	if err := initSigningSecretCipher(); err != nil {
+		return err
+	}
+	apiKeyRepo = repo
+	return nil
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up by
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func GetAPIKeyFromContext(c *gin.Context) (*models.APIKey, bool) {
+	value, exists := c.Get("api_key")
+	if !exists {
+		return nil, false
+	}
+	key, ok := value.(*models.APIKey)
+	return key, ok
+}
+
+// authenticateAPIKey authenticates a request that carries the API key
+// itself. Routes that move funds need a signed request instead.
+func authenticateAPIKey(c *gin.Context, raw string, scopes []string) {
+	if len(scopes) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot access this endpoint"})
+		c.Abort()
+		return
+	}
+	if requiresSignature(scopes) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This endpoint requires a signed request"})
+		c.Abort()
+		return
+	}
+	if apiKeyRepo == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+
+	key, err := apiKeyRepo.FindByHash(HashAPIKey(raw))
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		} else {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	authorizeAPIKey(c, key, scopes)
+}
+
+// authorizeAPIKey checks that the key is active, the IP allowlist and the
+// scopes the route requires, then continues the chain as the key's owner
+func authorizeAPIKey(c *gin.Context, key *models.APIKey, scopes []string) {
+	now := time.Now().UTC()
+	if !key.IsActive(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+	if !ipAllowed(c.ClientIP(), key.AllowedIPList()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "IP address not allowed for this API key"})
+		c.Abort()
+		return
+	}
+	for _, scope := range scopes {
+		if !key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key lacks required scope %s", scope)})
+			c.Abort()
+			return
+		}
+	}
+
+	if err := apiKeyRepo.TouchLastUsed(key.ID, now, apiKeyTouchInterval); err != nil {
+		log.Printf("Failed to update last use of API key %s: %v", key.ID, err)
+	}
+
+	c.Set("user_id", key.UserID)
+	c.Set("api_key", key)
+	c.Next()
+}
+
+// ipAllowed reports whether ip matches one of the allowed IPs or CIDR
+// ranges. An empty allowlist allows every IP.
+func ipAllowed(ip string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if allowedAddr := net.ParseIP(entry); allowedAddr != nil && allowedAddr.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
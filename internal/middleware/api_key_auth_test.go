@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testAPIKey = APIKeyPrefix + "test-key"
+
+// apiKeyTestServer serves routes behind AuthMiddleware for key, trusting
+// X-Forwarded-For only from trustedProxies like the real server does
+func apiKeyTestServer(t *testing.T, key *models.APIKey, trustedProxies []string) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	key.KeyHash = HashAPIKey(testAPIKey)
+	if err := UseAPIKeys(&fakeAPIKeyRepo{key: key}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { apiKeyRepo = nil })
+
+	router := gin.New()
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.MustGet("user_id")})
+	}
+	router.GET("/wallet", AuthMiddleware(models.ScopeReadWallet), ok)
+	router.GET("/transactions", AuthMiddleware(models.ScopeReadTransactions), ok)
+	router.POST("/wallet/withdraw", AuthMiddleware(models.ScopeTrade), ok)
+	router.GET("/profile", AuthMiddleware(), ok)
+	return router
+}
+
+// sendWithAPIKey calls target with the API key from remoteAddr
+func sendWithAPIKey(router http.Handler, method, target, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-API-Key", testAPIKey)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func newTestAPIKey(scopes ...string) *models.APIKey {
+	return &models.APIKey{ID: uuid.New(), UserID: uuid.New(), Scopes: strings.Join(scopes, ",")}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	router := apiKeyTestServer(t, newTestAPIKey(models.ScopeReadWallet), nil)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		wantStatus   int
+		wantInError  string
+		bearerHeader bool
+	}{
+		{name: "granted scope", method: http.MethodGet, target: "/wallet", wantStatus: http.StatusOK},
+		{name: "granted scope as bearer", method: http.MethodGet, target: "/wallet", wantStatus: http.StatusOK, bearerHeader: true},
+		{name: "missing scope", method: http.MethodGet, target: "/transactions", wantStatus: http.StatusForbidden, wantInError: "lacks required scope " + models.ScopeReadTransactions},
+		{name: "route without scopes", method: http.MethodGet, target: "/profile", wantStatus: http.StatusForbidden, wantInError: "cannot access this endpoint"},
+		{name: "route needing a signature", method: http.MethodPost, target: "/wallet/withdraw", wantStatus: http.StatusUnauthorized, wantInError: "requires a signed request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.bearerHeader {
+				req.Header.Set("Authorization", "Bearer "+testAPIKey)
+			} else {
+				req.Header.Set("X-API-Key", testAPIKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantInError) {
+				t.Errorf("got %d %s, want %d containing %q", w.Code, w.Body.String(), tt.wantStatus, tt.wantInError)
+			}
+		})
+	}
+}
+
+func TestAPIKeyIPAllowlist(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "no allowlist", allowed: "", remoteAddr: "198.51.100.7:4321", wantStatus: http.StatusOK},
+		{name: "exact IP", allowed: "203.0.113.10", remoteAddr: "203.0.113.10:4321", wantStatus: http.StatusOK},
+		{name: "other IP", allowed: "203.0.113.10", remoteAddr: "203.0.113.11:4321", wantStatus: http.StatusForbidden},
+		{name: "inside CIDR", allowed: "203.0.113.10,10.0.0.0/8", remoteAddr: "10.20.30.40:4321", wantStatus: http.StatusOK},
+		{name: "outside CIDR", allowed: "10.0.0.0/8", remoteAddr: "11.0.0.1:4321", wantStatus: http.StatusForbidden},
+		{name: "IPv6 CIDR", allowed: "2001:db8::/32", remoteAddr: "[2001:db8::1]:4321", wantStatus: http.StatusOK},
+		{name: "IPv6 outside CIDR", allowed: "2001:db8::/32", remoteAddr: "[2001:db9::1]:4321", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newTestAPIKey(models.ScopeReadWallet)
+			key.AllowedIPs = tt.allowed
+			router := apiKeyTestServer(t, key, nil)
+
+			w := sendWithAPIKey(router, http.MethodGet, "/wallet", tt.remoteAddr, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d %s, want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAllowlistUsesForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	forwarded := http.Header{"X-Forwarded-For": {"203.0.113.10"}}
+
+	key := newTestAPIKey(models.ScopeReadWallet)
+	key.AllowedIPs = "203.0.113.10"
+	router := apiKeyTestServer(t, key, nil)
+	if w := sendWithAPIKey(router, http.MethodGet, "/wallet", "198.51.100.7:4321", forwarded); w.Code != http.StatusForbidden {
+		t.Errorf("spoofed X-Forwarded-For without trusted proxies: status = %d, want 403", w.Code)
+	}
+
+	router = apiKeyTestServer(t, key, []string{"198.51.100.0/24"})
+	if w := sendWithAPIKey(router, http.MethodGet, "/wallet", "198.51.100.7:4321", forwarded); w.Code != http.StatusOK {
+		t.Errorf("X-Forwarded-For from a trusted proxy: status = %d %s, want 200", w.Code, w.Body.String())
+	}
+	if w := sendWithAPIKey(router, http.MethodGet, "/wallet", "192.0.2.1:4321", forwarded); w.Code != http.StatusForbidden {
+		t.Errorf("X-Forwarded-For from an untrusted address: status = %d, want 403", w.Code)
+	}
+}
+
+func TestAPIKeyExpiryAndRevocation(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		expiresAt  *time.Time
+		revokedAt  *time.Time
+		wantStatus int
+	}{
+		{name: "active", wantStatus: http.StatusOK},
+		{name: "expires later", expiresAt: &future, wantStatus: http.StatusOK},
+		{name: "expired", expiresAt: &past, wantStatus: http.StatusUnauthorized},
+		{name: "revoked", revokedAt: &past, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newTestAPIKey(models.ScopeReadWallet)
+			key.ExpiresAt = tt.expiresAt
+			key.RevokedAt = tt.revokedAt
+			router := apiKeyTestServer(t, key, nil)
+
+			w := sendWithAPIKey(router, http.MethodGet, "/wallet", "198.51.100.7:4321", nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d %s, want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+
+	router := apiKeyTestServer(t, newTestAPIKey(models.ScopeReadWallet), nil)
+	req := httptest.NewRequest(http.MethodGet, "/wallet", nil)
+	req.Header.Set("X-API-Key", APIKeyPrefix+"unknown")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"crypto-wallet-service/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+
+	// idempotencyFallbackTTL is how long a processing marker is kept when
+	// the completed response could not be stored
+	idempotencyFallbackTTL = time.Minute
+)
+
+const (
+	idempotencyStateProcessing = "processing"
+	idempotencyStateCompleted  = "completed"
+)
+
+// idempotencyRecord is what gets stored in Redis for every user+key pair
+type idempotencyRecord struct {
+	State       string `json:"state"`
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// responseRecorder captures the response body while still writing it to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// IdempotencyMiddleware honors the Idempotency-Key header on money-moving
+// endpoints. The first response for a user+key is stored in Redis and replayed
+// for retries; reusing the key with a different payload returns 422. Requests
+// without the header are passed through unchanged. Must run after AuthMiddleware.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		userID, err := GetUserIDFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		cacheKey := fmt.Sprintf("idempotency:%s:%s", userID, key)
+		requestHash := hashRequest(c.Request.Method, c.FullPath(), body)
+		ttl := config.GetIdempotencyTTL()
+
+		reserved, err := reserveIdempotencyKey(ctx, cacheKey, requestHash, ttl)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Idempotency store unavailable"})
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			replayIdempotentResponse(c, cacheKey, requestHash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored so the client can safely retry, and
+		// neither are two-factor rejections so it can retry with a code
+		if recorder.Status() >= http.StatusInternalServerError ||
+			recorder.Status() == http.StatusForbidden || recorder.Status() == http.StatusTooManyRequests {
+			if err := config.RedisClient.Del(context.Background(), cacheKey).Err(); err != nil {
+				log.Printf("Idempotency: failed to release key %s: %v", cacheKey, err)
+				shortenIdempotencyMarker(cacheKey)
+			}
+			return
+		}
+
+		record := idempotencyRecord{
+			State:       idempotencyStateCompleted,
+			RequestHash: requestHash,
+			StatusCode:  recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		data, _ := json.Marshal(record)
+		if err := config.RedisClient.Set(context.Background(), cacheKey, data, ttl).Err(); err != nil {
+			// Without the stored response, retries would get 409 until the
+			// processing marker expires
+			log.Printf("Idempotency: failed to store response for key %s: %v", cacheKey, err)
+			shortenIdempotencyMarker(cacheKey)
+		}
+	}
+}
+
+// shortenIdempotencyMarker lets a processing marker that could not be
+// replaced or removed expire soon, so the key does not stay blocked for the
+// whole TTL
+func shortenIdempotencyMarker(cacheKey string) {
+	if err := config.RedisClient.Expire(context.Background(), cacheKey, idempotencyFallbackTTL).Err(); err != nil {
+		log.Printf("Idempotency: failed to shorten marker for key %s: %v", cacheKey, err)
+	}
+}
+
+// reserveIdempotencyKey marks the key as in progress. It returns false when a
+// record for the key already exists.
+func reserveIdempotencyKey(ctx context.Context, cacheKey, requestHash string, ttl time.Duration) (bool, error) {
+	data, _ := json.Marshal(idempotencyRecord{
+		State:       idempotencyStateProcessing,
+		RequestHash: requestHash,
+	})
+	return config.RedisClient.SetNX(ctx, cacheKey, data, ttl).Result()
+}
+
+func replayIdempotentResponse(c *gin.Context, cacheKey, requestHash string) {
+	defer c.Abort()
+
+	cached, err := config.RedisClient.Get(c.Request.Context(), cacheKey).Bytes()
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(cached, &record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Corrupted idempotency record"})
+		return
+	}
+
+	if record.RequestHash != requestHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request payload"})
+		return
+	}
+
+	if record.State != idempotencyStateCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	c.Header(idempotencyReplayedHeader, "true")
+	c.Data(record.StatusCode, record.ContentType, record.Body)
+}
+
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"crypto-wallet-service/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// idempotencyTestServer serves POST /withdraw behind IdempotencyMiddleware
+// for a fixed user. handle decides the response and runs once per request
+// that reaches the handler.
+func idempotencyTestServer(t *testing.T, handle func(c *gin.Context)) (*miniredis.Miniredis, http.Handler, *int32) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Idempotency: config.IdempotencyConfig{TTLSeconds: 3600}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	redisServer := startTestRedis(t)
+	userID := uuid.New()
+	var calls int32
+
+	router := gin.New()
+	router.POST("/withdraw", func(c *gin.Context) {
+		c.Set("user_id", userID)
+	}, IdempotencyMiddleware(), func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		handle(c)
+	})
+
+	return redisServer, router, &calls
+}
+
+func sendIdempotent(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/withdraw", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysCompletedResponse(t *testing.T) {
+	_, router, calls := idempotencyTestServer(t, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+	})
+
+	first := sendIdempotent(router, "key-1", `{"amount":"1"}`)
+	second := sendIdempotent(router, "key-1", `{"amount":"1"}`)
+
+	if *calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", *calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replayed %d %q, want %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Errorf("replayed response is missing the %s header", idempotencyReplayedHeader)
+	}
+	if first.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("first response must not be marked as replayed")
+	}
+}
+
+func TestIdempotencyRejectsDifferentPayload(t *testing.T) {
+	_, router, calls := idempotencyTestServer(t, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+	})
+
+	sendIdempotent(router, "key-1", `{"amount":"1"}`)
+	w := sendIdempotent(router, "key-1", `{"amount":"2"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want 1", *calls)
+	}
+}
+
+func TestIdempotencyRejectsRequestInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	_, router, calls := idempotencyTestServer(t, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- sendIdempotent(router, "key-1", `{"amount":"1"}`)
+	}()
+	<-started
+
+	w := sendIdempotent(router, "key-1", `{"amount":"1"}`)
+	close(release)
+	first := <-done
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status while in flight = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if first.Code != http.StatusOK {
+		t.Errorf("first request status = %d, want %d", first.Code, http.StatusOK)
+	}
+	if *calls != 1 {
+		t.Errorf("handler ran %d times, want 1", *calls)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var fail int32 = 1
+	_, router, calls := idempotencyTestServer(t, func(c *gin.Context) {
+		if atomic.SwapInt32(&fail, 0) == 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+	})
+
+	first := sendIdempotent(router, "key-1", `{"amount":"1"}`)
+	retry := sendIdempotent(router, "key-1", `{"amount":"1"}`)
+
+	if first.Code != http.StatusInternalServerError || retry.Code != http.StatusOK {
+		t.Errorf("statuses = %d, %d, want 500, 200", first.Code, retry.Code)
+	}
+	if *calls != 2 {
+		t.Errorf("handler ran %d times, want 2", *calls)
+	}
+}
+
+func TestIdempotencyShortensMarkerWhenStoreFails(t *testing.T) {
+	redisServer, router, _ := idempotencyTestServer(t, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+	})
+	// Every plain SET fails; the SET NX taking the processing marker works
+	redisServer.Server().SetPreHook(func(peer *server.Peer, cmd string, args ...string) bool {
+		if !strings.EqualFold(cmd, "SET") {
+			return false
+		}
+		for _, arg := range args {
+			if strings.EqualFold(arg, "NX") {
+				return false
+			}
+		}
+		peer.WriteError("ERR store failed")
+		return true
+	})
+
+	w := sendIdempotent(router, "key-1", `{"amount":"1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	keys := redisServer.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("found %d keys, want the processing marker only", len(keys))
+	}
+	marker := keys[0]
+	if value, _ := redisServer.Get(marker); !strings.Contains(value, idempotencyStateProcessing) {
+		t.Errorf("marker = %s, want a processing record", value)
+	}
+	if ttl := redisServer.TTL(marker); ttl <= 0 || ttl > idempotencyFallbackTTL {
+		t.Errorf("marker TTL = %s, want at most %s", ttl, idempotencyFallbackTTL)
+	}
+}
+
+func TestIdempotencyWithoutHeaderPassesThrough(t *testing.T) {
+	_, router, calls := idempotencyTestServer(t, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+	})
+
+	sendIdempotent(router, "", `{"amount":"1"}`)
+	sendIdempotent(router, "", `{"amount":"1"}`)
+
+	if *calls != 2 {
+		t.Errorf("handler ran %d times, want 2", *calls)
+	}
+}
@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing
+const minRSAKeyBits = 2048
+
+// signingKey is one asymmetric access token key, identified by kid
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	notBefore time.Time // when the key starts signing
+}
+
+// keySet holds the configured signing keys ordered by notBefore. The newest
+// key that is already active signs new tokens. A key that was replaced keeps
+// verifying tokens for `overlap` after its successor took over, so tokens
+// issued just before a rotation stay valid until they expire. Keys that are
+// scheduled but not active yet are published too, letting verifiers cache
+// them before the first token is signed with them.
+type keySet struct {
+	keys    []signingKey
+	overlap time.Duration
+}
+
+// signingKeys is nil when no keys are configured; tokens then use HS256
+var signingKeys *keySet
+
+// LoadSigningKeys reads the PEM keys listed in JWT_KEYS. It must run after
+// config.LoadConfig and before tokens are issued or verified.
+func LoadSigningKeys() error {
+	if len(config.AppConfig.JWT.Keys) == 0 {
+		signingKeys = nil
+		return nil
+	}
+
+	set := &keySet{overlap: config.GetJWTKeyOverlap()}
+	seen := make(map[string]bool)
+	for _, entry := range config.AppConfig.JWT.Keys {
+		key, err := loadSigningKey(entry)
+		if err != nil {
+			return err
+		}
+		if seen[key.id] {
+			return fmt.Errorf("duplicate JWT key id %q", key.id)
+		}
+		seen[key.id] = true
+		set.keys = append(set.keys, *key)
+	}
+
+	sort.SliceStable(set.keys, func(i, j int) bool {
+		return set.keys[i].notBefore.Before(set.keys[j].notBefore)
+	})
+	if set.signing(time.Now()) == nil {
+		return errors.New("no JWT signing key is active yet")
+	}
+
+	signingKeys = set
+	return nil
+}
+
+// PublicJWKS returns the public keys that currently verify tokens, or an
+// empty set when tokens are signed with the shared HS256 secret
+func PublicJWKS() models.JWKSet {
+	set := models.JWKSet{Keys: []models.JWK{}}
+	if signingKeys == nil {
+		return set
+	}
+
+	now := time.Now()
+	for i, key := range signingKeys.keys {
+		if signingKeys.retired(i, now) {
+			continue
+		}
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	return set
+}
+
+// signToken signs the claims with the active key, or with the HS256 secret
+// when no keys are configured
+func signToken(claims jwt.Claims) (string, error) {
+	if signingKeys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	}
+
+	key := signingKeys.signing(time.Now())
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// verificationKey is the jwt.Keyfunc for access tokens. Once asymmetric keys
+// are configured HS256 tokens are rejected, so the secret cannot be used to
+// forge tokens anymore.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if signingKeys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return []byte(config.AppConfig.JWT.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key := signingKeys.verifying(kid, time.Now())
+	if key == nil {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.private.Public(), nil
+}
+
+// signing returns the newest key that is active at now
+func (s *keySet) signing(now time.Time) *signingKey {
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if !s.keys[i].notBefore.After(now) {
+			return &s.keys[i]
+		}
+	}
+	return nil
+}
+
+// verifying returns the key with the given kid unless it has been retired
+func (s *keySet) verifying(kid string, now time.Time) *signingKey {
+	for i := range s.keys {
+		if s.keys[i].id == kid {
+			if s.retired(i, now) {
+				return nil
+			}
+			return &s.keys[i]
+		}
+	}
+	return nil
+}
+
+// retired reports whether the overlap after the next key took over has passed
+func (s *keySet) retired(i int, now time.Time) bool {
+	if i == len(s.keys)-1 {
+		return false
+	}
+	return now.After(s.keys[i+1].notBefore.Add(s.overlap))
+}
+
+func (k *signingKey) jwk() models.JWK {
+	jwk := models.JWK{
+		Kid: k.id,
+		Use: "sig",
+		Alg: k.method.Alg(),
+	}
+
+	switch public := k.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// loadSigningKey parses one JWT_KEYS entry: kid=path.pem, optionally
+// followed by @ and the RFC 3339 time the key starts signing
+func loadSigningKey(entry string) (*signingKey, error) {
+	kid, path, ok := strings.Cut(entry, "=")
+	kid = strings.TrimSpace(kid)
+	if !ok || kid == "" {
+		return nil, fmt.Errorf("invalid JWT key %q, expected kid=path.pem[@time]", entry)
+	}
+
+	var notBefore time.Time
+	if at := strings.LastIndex(path, "@"); at >= 0 {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(path[at+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time for JWT key %q: %w", kid, err)
+		}
+		notBefore = t
+		path = path[:at]
+	}
+
+	data, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %q: %w", kid, err)
+	}
+
+	private, method, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT key %q: %w", kid, err)
+	}
+
+	return &signingKey{
+		id:        kid,
+		method:    method,
+		private:   private,
+		notBefore: notBefore,
+	}, nil
+}
+
+// parsePrivateKey accepts RSA keys (PKCS #1 or PKCS #8) for RS256 and
+// Ed25519 keys (PKCS #8) for EdDSA
+func parsePrivateKey(data []byte) (crypto.Signer, jwt.SigningMethod, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		return key, jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey:
+		return key, jwt.SigningMethodEdDSA, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+}
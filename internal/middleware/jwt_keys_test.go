@@ -0,0 +1,358 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto-wallet-service/config"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writePEM stores the key in a temporary file, as PKCS #1 for RSA keys when
+// pkcs1 is set and as PKCS #8 otherwise, and returns its path
+func writePEM(t *testing.T, key crypto.Signer, pkcs1 bool) string {
+	t.Helper()
+
+	block := &pem.Block{Type: "PRIVATE KEY"}
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok && pkcs1 {
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Bytes = der
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// keyEntry formats a JWT_KEYS entry for a key that starts signing at since
+func keyEntry(kid, path string, since time.Time) string {
+	return kid + "=" + path + "@" + since.UTC().Format(time.RFC3339)
+}
+
+// useJWTKeys loads the JWT_KEYS entries with the given overlap
+func useJWTKeys(t *testing.T, overlapMinutes int, entries ...string) error {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{
+		Secret:            "test-secret",
+		AccessTTLMinutes:  15,
+		Keys:              entries,
+		KeyOverlapMinutes: overlapMinutes,
+	}}
+	t.Cleanup(func() {
+		config.AppConfig = previous
+		signingKeys = nil
+	})
+	return LoadSigningKeys()
+}
+
+// signWithKid signs a token with the configured key kid, the way tokens
+// were signed while that key was active
+func signWithKid(t *testing.T, kid string) string {
+	t.Helper()
+
+	for _, key := range signingKeys.keys {
+		if key.id != kid {
+			continue
+		}
+		token := jwt.NewWithClaims(key.method, testClaims())
+		token.Header["kid"] = key.id
+		signed, err := token.SignedString(key.private)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	t.Fatalf("no key %q is configured", kid)
+	return ""
+}
+
+func testClaims() JWTClaims {
+	now := time.Now()
+	return JWTClaims{
+		UserID:    uuid.New(),
+		SessionID: uuid.New(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestLoadSigningKeysFromPEM(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	since := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		path    string
+		wantAlg string
+		wantErr string
+	}{
+		{name: "RSA PKCS #1", path: writePEM(t, rsaKey, true), wantAlg: "RS256"},
+		{name: "RSA PKCS #8", path: writePEM(t, rsaKey, false), wantAlg: "RS256"},
+		{name: "Ed25519", path: writePEM(t, newEd25519Key(t), false), wantAlg: "EdDSA"},
+		{name: "short RSA key", path: writePEM(t, newRSAKey(t, 1024), true), wantErr: "at least 2048 bits"},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.pem"), wantErr: "failed to read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := useJWTKeys(t, 0, keyEntry("k1", tt.path, since))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alg := signingKeys.keys[0].method.Alg(); alg != tt.wantAlg {
+				t.Errorf("alg = %s, want %s", alg, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKeysRejectsInvalidSets(t *testing.T) {
+	path := writePEM(t, newEd25519Key(t), false)
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		entries []string
+		wantErr string
+	}{
+		{name: "no PEM block", entries: []string{keyEntry("k1", garbage, now)}, wantErr: "no PEM block"},
+		{name: "missing kid", entries: []string{"=" + path}, wantErr: "expected kid=path.pem"},
+		{name: "bad start time", entries: []string{"k1=" + path + "@yesterday"}, wantErr: "invalid start time"},
+		{name: "duplicate kid", entries: []string{keyEntry("k1", path, now.Add(-time.Hour)), keyEntry("k1", path, now.Add(-time.Minute))}, wantErr: "duplicate"},
+		{name: "only future keys", entries: []string{keyEntry("k1", path, now.Add(time.Hour))}, wantErr: "no JWT signing key is active"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := useJWTKeys(t, 0, tt.entries...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenSignsWithNewestActiveKey(t *testing.T) {
+	now := time.Now()
+	err := useJWTKeys(t, 10,
+		// listed out of order on purpose
+		keyEntry("next", writePEM(t, newEd25519Key(t), false), now.Add(time.Hour)),
+		keyEntry("old", writePEM(t, newRSAKey(t, 2048), true), now.Add(-24*time.Hour)),
+		keyEntry("current", writePEM(t, newEd25519Key(t), false), now.Add(-time.Minute)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := GenerateToken(uuid.New(), "user@example.com", uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, &JWTClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := token.Header["kid"]; kid != "current" {
+		t.Errorf("kid = %v, want current", kid)
+	}
+	if alg := token.Method.Alg(); alg != "EdDSA" {
+		t.Errorf("alg = %s, want EdDSA", alg)
+	}
+	if _, err := ValidateToken(signed); err != nil {
+		t.Errorf("token signed with the active key: %v", err)
+	}
+}
+
+func TestValidateTokenDuringKeyOverlap(t *testing.T) {
+	oldPath := writePEM(t, newRSAKey(t, 2048), true)
+	newPath := writePEM(t, newEd25519Key(t), false)
+
+	t.Run("within the overlap", func(t *testing.T) {
+		now := time.Now()
+		if err := useJWTKeys(t, 10, keyEntry("old", oldPath, now.Add(-time.Hour)), keyEntry("new", newPath, now.Add(-5*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ValidateToken(signWithKid(t, "old")); err != nil {
+			t.Errorf("token of the replaced key: %v", err)
+		}
+		if _, err := ValidateToken(signWithKid(t, "new")); err != nil {
+			t.Errorf("token of the active key: %v", err)
+		}
+	})
+
+	t.Run("after the overlap", func(t *testing.T) {
+		now := time.Now()
+		if err := useJWTKeys(t, 10, keyEntry("old", oldPath, now.Add(-time.Hour)), keyEntry("new", newPath, now.Add(-15*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ValidateToken(signWithKid(t, "old")); err == nil {
+			t.Error("token of a retired key was accepted")
+		}
+	})
+
+	t.Run("overlap defaults to the access token TTL", func(t *testing.T) {
+		now := time.Now()
+		if err := useJWTKeys(t, 0, keyEntry("old", oldPath, now.Add(-time.Hour)), keyEntry("new", newPath, now.Add(-10*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ValidateToken(signWithKid(t, "old")); err != nil {
+			t.Errorf("token of the replaced key within the access TTL: %v", err)
+		}
+	})
+}
+
+func TestValidateTokenRejectsForeignTokens(t *testing.T) {
+	now := time.Now()
+	if err := useJWTKeys(t, 10,
+		keyEntry("rsa", writePEM(t, newRSAKey(t, 2048), true), now.Add(-time.Hour)),
+		keyEntry("ed", writePEM(t, newEd25519Key(t), false), now.Add(-time.Minute)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(hs256); err == nil {
+		t.Error("an HS256 token signed with JWT_SECRET was accepted")
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+	unknown.Header["kid"] = "ed"
+	forged, err := unknown.SignedString(newEd25519Key(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(forged); err == nil {
+		t.Error("a token signed with another key under a known kid was accepted")
+	}
+
+	// The RSA key signing under the kid of the Ed25519 key
+	var rsaKey crypto.Signer
+	for _, key := range signingKeys.keys {
+		if key.id == "rsa" {
+			rsaKey = key.private
+		}
+	}
+	mixed := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+	mixed.Header["kid"] = "ed"
+	signed, err := mixed.SignedString(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(signed); err == nil {
+		t.Error("a token whose alg does not match its kid was accepted")
+	}
+}
+
+func TestValidateTokenWithSharedSecret(t *testing.T) {
+	if err := useJWTKeys(t, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := GenerateToken(uuid.New(), "user@example.com", uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(signed); err != nil {
+		t.Errorf("HS256 token without configured keys: %v", err)
+	}
+	if set := PublicJWKS(); len(set.Keys) != 0 {
+		t.Errorf("JWKS = %+v, want no keys", set.Keys)
+	}
+}
+
+func TestPublicJWKS(t *testing.T) {
+	rsaKey := newRSAKey(t, 2048)
+	edKey := newEd25519Key(t)
+	now := time.Now()
+	if err := useJWTKeys(t, 10,
+		keyEntry("retired", writePEM(t, newEd25519Key(t), false), now.Add(-48*time.Hour)),
+		keyEntry("rsa", writePEM(t, rsaKey, true), now.Add(-24*time.Hour)),
+		keyEntry("ed", writePEM(t, edKey, false), now.Add(-time.Minute)),
+		keyEntry("next", writePEM(t, newEd25519Key(t), false), now.Add(time.Hour)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	set := PublicJWKS()
+	var kids []string
+	for _, key := range set.Keys {
+		kids = append(kids, key.Kid)
+	}
+	if got := strings.Join(kids, ","); got != "rsa,ed,next" {
+		t.Fatalf("kids = %s, want rsa,ed,next", got)
+	}
+
+	rsaJWK := set.Keys[0]
+	if rsaJWK.Kty != "RSA" || rsaJWK.Alg != "RS256" || rsaJWK.Use != "sig" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+	n, _ := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	e, _ := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	if new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || int(new(big.Int).SetBytes(e).Int64()) != rsaKey.E {
+		t.Error("RSA JWK does not match the public key")
+	}
+
+	edJWK := set.Keys[1]
+	if edJWK.Kty != "OKP" || edJWK.Crv != "Ed25519" || edJWK.Alg != "EdDSA" || edJWK.Use != "sig" {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(edJWK.X)
+	if !ed25519.PublicKey(x).Equal(edKey.Public()) {
+		t.Error("Ed25519 JWK does not match the public key")
+	}
+	if edJWK.N != "" || edJWK.E != "" {
+		t.Errorf("Ed25519 JWK has RSA fields: %+v", edJWK)
+	}
+}
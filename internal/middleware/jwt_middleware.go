@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/repository"
 	"errors"
 	"net/http"
 	"strings"
@@ -14,34 +15,34 @@ import (
 
 
 type JWTClaims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	SessionID uuid.UUID `json:"sid"` // Refresh token family the access token was issued for
 	jwt.RegisteredClaims
 }
 
 
-func GenerateToken(userID uuid.UUID, email string) (string, error) {
+// GenerateToken issues a short-lived access token for a login session,
+// signed with the active key from the JWT key set
+func GenerateToken(userID uuid.UUID, email string, sessionID uuid.UUID) (string, error) {
+	now := time.Now()
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.GetAccessTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	return signToken(claims)
 }
 
 
 func ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(config.AppConfig.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, verificationKey)
 
 	if err != nil {
 		return nil, err
@@ -55,8 +56,21 @@ func ValidateToken(tokenString string) (*JWTClaims, error) {
 }
 
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware authenticates a bearer JWT. Routes that pass scopes also
+// accept an API key, sent as X-API-Key or as the bearer token, or a request
+// signed with the key's signing secret, as long as the key has every scope.
+// Routes without scopes are for login sessions only.
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.GetHeader(HeaderAPISignature) != "" {
+			authenticateSignedRequest(c, scopes)
+			return
+		}
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey, scopes)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -73,6 +87,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
+		if strings.HasPrefix(tokenString, APIKeyPrefix) {
+			authenticateAPIKey(c, tokenString, scopes)
+			return
+		}
+
 		claims, err := ValidateToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
@@ -80,14 +99,42 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		revoked, err := IsTokenRevoked(c.Request.Context(), claims)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Set("token_claims", claims)
 		c.Next()
 	}
 }
 
 
+func GetClaimsFromContext(c *gin.Context) (*JWTClaims, error) {
+	value, exists := c.Get("token_claims")
+	if !exists {
+		return nil, errors.New("token claims not found in context")
+	}
+
+	claims, ok := value.(*JWTClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims format")
+	}
+
+	return claims, nil
+}
+
+
 func GetUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -101,3 +148,27 @@ func GetUserIDFromContext(c *gin.Context) (uuid.UUID, error) {
 
 	return id, nil
 }
+
+
+// AdminMiddleware only lets users with the admin role through. The role is
+// read from the database on every request so demotions apply immediately.
+// Must run after AuthMiddleware.
+func AdminMiddleware(userRepo repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserIDFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		user, err := userRepo.FindByID(userID)
+		if err != nil || !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/secrets"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Headers of a signed API key request
+const (
+	HeaderAPIKeyID     = "X-API-Key-ID"
+	HeaderAPITimestamp = "X-API-Timestamp"
+	HeaderAPINonce     = "X-API-Nonce"
+	HeaderAPISignature = "X-API-Signature"
+)
+
+const (
+	minNonceLength = 16
+	maxNonceLength = 64
+
+	// maxSignedBodySize bounds the body read to verify a signature. It leaves
+	// room for a 5 MB CSV import with its multipart framing.
+	maxSignedBodySize = 6 << 20
+)
+
+// signedScopes are the scopes that move funds. Routes requiring one of them
+// only accept API keys on signed requests, so a captured request cannot be
+// replayed.
+var signedScopes = map[string]bool{
+	models.ScopeTrade:    true,
+	models.ScopeWithdraw: true,
+}
+
+var signingSecrets *secrets.Box
+
+// initSigningSecretCipher loads API_KEY_ENCRYPTION_KEY, the key signing
+// secrets are encrypted with. Release mode refuses to start without it.
+func initSigningSecretCipher() error {
+	box, err := secrets.Load("API_KEY_ENCRYPTION_KEY", config.AppConfig.APIKey.EncryptionKey, "api-key-signing-secret")
+	if err != nil {
+		return err
+	}
+	signingSecrets = box
+	return nil
+}
+
+// EncryptSigningSecret encrypts an API key signing secret for storage. The
+// key ID is authenticated too, so a secret cannot be moved to another key.
+func EncryptSigningSecret(keyID uuid.UUID, secret string) (string, error) {
+	if signingSecrets == nil {
+		return "", errors.New("signing secret encryption is not configured")
+	}
+	return signingSecrets.Seal(keyID[:], secret)
+}
+
+func decryptSigningSecret(keyID uuid.UUID, encrypted string) ([]byte, error) {
+	if signingSecrets == nil {
+		return nil, errors.New("signing secret encryption is not configured")
+	}
+	return signingSecrets.Open(keyID[:], encrypted)
+}
+
+// StringToSign builds what a signed request signs: method, path, raw query,
+// timestamp, nonce and the hex SHA-256 of the body, one per line
+func StringToSign(method, path, rawQuery, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return method + "\n" + path + "\n" + rawQuery + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])
+}
+
+// requiresSignature reports whether API keys must sign requests to a route
+func requiresSignature(scopes []string) bool {
+	for _, scope := range scopes {
+		if signedScopes[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateSignedRequest verifies an HMAC-SHA256 signed API key request.
+// The timestamp must be within the allowed skew and every nonce is accepted
+// once per key; nonces are remembered in Redis for as long as their
+// timestamp could still be accepted.
+func authenticateSignedRequest(c *gin.Context, scopes []string) {
+	if len(scopes) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot access this endpoint"})
+		c.Abort()
+		return
+	}
+
+	keyID, err := uuid.Parse(c.GetHeader(HeaderAPIKeyID))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing " + HeaderAPIKeyID})
+		c.Abort()
+		return
+	}
+
+	timestamp := c.GetHeader(HeaderAPITimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing " + HeaderAPITimestamp})
+		c.Abort()
+		return
+	}
+	maxSkew := config.GetSignatureMaxSkew()
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Request timestamp is outside the allowed window"})
+		c.Abort()
+		return
+	}
+
+	nonce := c.GetHeader(HeaderAPINonce)
+	if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("%s must be %d to %d characters", HeaderAPINonce, minNonceLength, maxNonceLength)})
+		c.Abort()
+		return
+	}
+
+	signature, err := hex.DecodeString(c.GetHeader(HeaderAPISignature))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid request signature"})
+		c.Abort()
+		return
+	}
+
+	if apiKeyRepo == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+	key, err := apiKeyRepo.FindByID(keyID)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		} else {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify API key"})
+		}
+		c.Abort()
+		return
+	}
+	if key.SigningSecret == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has no signing secret, create a new key"})
+		c.Abort()
+		return
+	}
+	secret, err := decryptSigningSecret(key.ID, key.SigningSecret)
+	if err != nil {
+		log.Printf("Failed to decrypt signing secret of API key %s: %v", key.ID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify API key"})
+		c.Abort()
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		}
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(StringToSign(c.Request.Method, c.Request.URL.EscapedPath(), c.Request.URL.RawQuery, timestamp, nonce, body)))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid request signature"})
+		c.Abort()
+		return
+	}
+
+	// Only a correctly signed request may burn a nonce
+	fresh, err := config.RedisClient.SetNX(context.Background(), apiNonceKey(key.ID, nonce), 1, 2*maxSkew).Result()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify request nonce"})
+		c.Abort()
+		return
+	}
+	if !fresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Request nonce has already been used"})
+		c.Abort()
+		return
+	}
+
+	authorizeAPIKey(c, key, scopes)
+}
+
+func apiNonceKey(keyID uuid.UUID, nonce string) string {
+	return fmt.Sprintf("api_nonce:%s:%s", keyID, nonce)
+}
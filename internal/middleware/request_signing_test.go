@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fakeAPIKeyRepo serves a single key. Only the methods used by API key
+// authentication are implemented.
+type fakeAPIKeyRepo struct {
+	repository.APIKeyRepository
+	key *models.APIKey
+}
+
+func (r *fakeAPIKeyRepo) FindByID(id uuid.UUID) (*models.APIKey, error) {
+	if r.key == nil || r.key.ID != id {
+		return nil, repository.ErrAPIKeyNotFound
+	}
+	key := *r.key
+	return &key, nil
+}
+
+func (r *fakeAPIKeyRepo) FindByHash(hash string) (*models.APIKey, error) {
+	if r.key == nil || r.key.KeyHash != hash {
+		return nil, repository.ErrAPIKeyNotFound
+	}
+	key := *r.key
+	return &key, nil
+}
+
+func (r *fakeAPIKeyRepo) TouchLastUsed(id uuid.UUID, at time.Time, every time.Duration) error {
+	return nil
+}
+
+type signedRequest struct {
+	method, target, body string
+	timestamp, nonce     string
+}
+
+// signingTestServer serves POST /wallet/withdraw behind AuthMiddleware with
+// the trade scope and returns the key ID and plain signing secret
+func signingTestServer(t *testing.T) (http.Handler, uuid.UUID, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{
+		JWT:    config.JWTConfig{Secret: "test-secret"},
+		APIKey: config.APIKeyConfig{SignatureMaxSkewSeconds: 30},
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	repo := &fakeAPIKeyRepo{}
+	if err := UseAPIKeys(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { apiKeyRepo = nil })
+	startTestRedis(t)
+
+	const secret = "signing-secret"
+	key := &models.APIKey{ID: uuid.New(), UserID: uuid.New(), Scopes: models.ScopeTrade}
+	encrypted, err := EncryptSigningSecret(key.ID, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.SigningSecret = encrypted
+	repo.key = key
+
+	router := gin.New()
+	router.POST("/wallet/withdraw", AuthMiddleware(models.ScopeTrade), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.MustGet("user_id")})
+	})
+	return router, key.ID, secret
+}
+
+func (r signedRequest) sign(secret string) string {
+	path, query, _ := strings.Cut(r.target, "?")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(StringToSign(r.method, path, query, r.timestamp, r.nonce, []byte(r.body))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendSigned(router http.Handler, keyID uuid.UUID, r signedRequest, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(r.method, r.target, strings.NewReader(r.body))
+	req.Header.Set(HeaderAPIKeyID, keyID.String())
+	req.Header.Set(HeaderAPITimestamp, r.timestamp)
+	req.Header.Set(HeaderAPINonce, r.nonce)
+	req.Header.Set(HeaderAPISignature, signature)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func newSignedRequest(body string) signedRequest {
+	return signedRequest{
+		method:    http.MethodPost,
+		target:    "/wallet/withdraw?currency=BTC",
+		body:      body,
+		timestamp: strconv.FormatInt(time.Now().Unix(), 10),
+		nonce:     strings.ReplaceAll(uuid.NewString(), "-", ""),
+	}
+}
+
+func TestStringToSign(t *testing.T) {
+	got := StringToSign("POST", "/api/wallet/withdraw", "currency=BTC", "1700000000", "0123456789abcdef", []byte(`{"amount":"1"}`))
+	bodyHash := sha256.Sum256([]byte(`{"amount":"1"}`))
+	want := "POST\n/api/wallet/withdraw\ncurrency=BTC\n1700000000\n0123456789abcdef\n" + hex.EncodeToString(bodyHash[:])
+	if got != want {
+		t.Errorf("string to sign = %q, want %q", got, want)
+	}
+
+	// An empty body signs the SHA-256 of nothing
+	empty := StringToSign("GET", "/api/wallet", "", "1700000000", "0123456789abcdef", nil)
+	if !strings.HasSuffix(empty, "\n\n1700000000\n0123456789abcdef\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855") {
+		t.Errorf("string to sign for an empty body = %q", empty)
+	}
+}
+
+func TestSignedRequestAccepted(t *testing.T) {
+	router, keyID, secret := signingTestServer(t)
+	r := newSignedRequest(`{"amount":"1"}`)
+
+	if w := sendSigned(router, keyID, r, r.sign(secret)); w.Code != http.StatusOK {
+		t.Errorf("status = %d %s, want %d", w.Code, w.Body.String(), http.StatusOK)
+	}
+}
+
+func TestSignedRequestRejectsTampering(t *testing.T) {
+	router, keyID, secret := signingTestServer(t)
+
+	tests := []struct {
+		name   string
+		tamper func(r *signedRequest)
+	}{
+		{"body", func(r *signedRequest) { r.body = `{"amount":"100"}` }},
+		{"query", func(r *signedRequest) { r.target = "/wallet/withdraw?currency=ETH" }},
+		{"nonce", func(r *signedRequest) { r.nonce += "x" }},
+		{"timestamp", func(r *signedRequest) { r.timestamp = strconv.FormatInt(time.Now().Unix()-1, 10) }},
+	}
+
+	for _, tt := range tests {
+		r := newSignedRequest(`{"amount":"1"}`)
+		signature := r.sign(secret)
+		tt.tamper(&r)
+
+		if w := sendSigned(router, keyID, r, signature); w.Code != http.StatusUnauthorized {
+			t.Errorf("tampered %s: status = %d, want 401", tt.name, w.Code)
+		}
+	}
+
+	r := newSignedRequest(`{"amount":"1"}`)
+	if w := sendSigned(router, keyID, r, r.sign("another-secret")); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong secret: status = %d, want 401", w.Code)
+	}
+}
+
+func TestSignedRequestRejectsStaleTimestamp(t *testing.T) {
+	router, keyID, secret := signingTestServer(t)
+
+	for _, offset := range []time.Duration{-time.Minute, time.Minute} {
+		r := newSignedRequest(`{"amount":"1"}`)
+		r.timestamp = strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+
+		w := sendSigned(router, keyID, r, r.sign(secret))
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "outside the allowed window") {
+			t.Errorf("timestamp %s from now: got %d %s, want 401", offset, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSignedRequestRejectsReplayedNonce(t *testing.T) {
+	router, keyID, secret := signingTestServer(t)
+	r := newSignedRequest(`{"amount":"1"}`)
+	signature := r.sign(secret)
+
+	if w := sendSigned(router, keyID, r, signature); w.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d %s", w.Code, w.Body.String())
+	}
+	w := sendSigned(router, keyID, r, signature)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "already been used") {
+		t.Errorf("replay: got %d %s, want 401 for a used nonce", w.Code, w.Body.String())
+	}
+}
+
+func TestSignedRequestWithBadSignatureDoesNotBurnNonce(t *testing.T) {
+	router, keyID, secret := signingTestServer(t)
+	r := newSignedRequest(`{"amount":"1"}`)
+
+	if w := sendSigned(router, keyID, r, r.sign("another-secret")); w.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: status = %d, want 401", w.Code)
+	}
+	if w := sendSigned(router, keyID, r, r.sign(secret)); w.Code != http.StatusOK {
+		t.Errorf("valid request after a bad one: status = %d %s, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestSignedRequestRejectsOversizedBody(t *testing.T) {
+	router, keyID, secret := signingTestServer(t)
+	r := newSignedRequest(strings.Repeat("x", maxSignedBodySize+1))
+
+	if w := sendSigned(router, keyID, r, r.sign(secret)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d %s, want %d", w.Code, w.Body.String(), http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestUseAPIKeysRequiresEncryptionKeyInRelease(t *testing.T) {
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	config.AppConfig = &config.Config{
+		Server: config.ServerConfig{Mode: "release"},
+		JWT:    config.JWTConfig{Secret: "your-super-secret-jwt-key"},
+	}
+	if err := UseAPIKeys(nil); err == nil || !strings.Contains(err.Error(), "API_KEY_ENCRYPTION_KEY") {
+		t.Errorf("without a key: err = %v, want API_KEY_ENCRYPTION_KEY to be required", err)
+	}
+
+	config.AppConfig.APIKey.EncryptionKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if err := UseAPIKeys(nil); err != nil {
+		t.Errorf("with a key: %v", err)
+	}
+}
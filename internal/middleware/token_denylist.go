@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crypto-wallet-service/config"
+
+	"github.com/google/uuid"
+)
+
+func revokedTokenKey(tokenID string) string {
+	return fmt.Sprintf("jwt:denylist:jti:%s", tokenID)
+}
+
+func revokedSessionKey(sessionID uuid.UUID) string {
+	return fmt.Sprintf("jwt:denylist:sid:%s", sessionID)
+}
+
+// RevokeAccessToken puts a single access token on the denylist until it expires
+func RevokeAccessToken(ctx context.Context, claims *JWTClaims) error {
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return config.RedisClient.Set(ctx, revokedTokenKey(claims.ID), 1, ttl).Err()
+}
+
+// RevokeSession rejects every access token issued for the session. Access
+// tokens live at most one access TTL, so the entry can expire after that.
+func RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	return config.RedisClient.Set(ctx, revokedSessionKey(sessionID), 1, config.GetAccessTokenTTL()).Err()
+}
+
+// IsTokenRevoked reports whether the token itself or its session was revoked
+func IsTokenRevoked(ctx context.Context, claims *JWTClaims) (bool, error) {
+	keys := []string{revokedTokenKey(claims.ID)}
+	if claims.SessionID != uuid.Nil {
+		keys = append(keys, revokedSessionKey(claims.SessionID))
+	}
+
+	count, err := config.RedisClient.Exists(ctx, keys...).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
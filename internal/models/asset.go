@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxAssetDecimals is the highest precision balance columns can store
+const MaxAssetDecimals = 18
+
+type AssetOperation string
+
+const (
+	AssetOperationDeposit  AssetOperation = "deposit"
+	AssetOperationWithdraw AssetOperation = "withdraw"
+	AssetOperationTransfer AssetOperation = "transfer"
+)
+
+// Asset is a currency the wallet supports. Assets live in the database so new
+// coins can be listed without a code change.
+type Asset struct {
+	Symbol          string          `gorm:"type:varchar(10);primary_key" json:"symbol"`
+	Name            string          `gorm:"type:varchar(100);not null" json:"name"`
+	ProviderID      string          `gorm:"type:varchar(100)" json:"provider_id"` // Id coin di CoinGecko, kosong untuk IDR
+	Decimals        int32           `gorm:"not null" json:"decimals"`
+	Enabled         bool            `gorm:"not null" json:"enabled"`
+	DepositEnabled  bool            `gorm:"not null" json:"deposit_enabled"`
+	WithdrawEnabled bool            `gorm:"not null" json:"withdraw_enabled"`
+	MinAmount       decimal.Decimal `gorm:"type:numeric(38,18);not null;default:0" json:"min_amount"` // 0 = tanpa batas
+	MaxAmount       decimal.Decimal `gorm:"type:numeric(38,18);not null;default:0" json:"max_amount"` // 0 = tanpa batas
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Allows reports whether the operation is currently enabled for the asset.
+// Transfers move funds out of the sender's wallet, so they follow
+// WithdrawEnabled; unknown operations are never allowed.
+func (a *Asset) Allows(op AssetOperation) bool {
+	if !a.Enabled {
+		return false
+	}
+
+	switch op {
+	case AssetOperationDeposit:
+		return a.DepositEnabled
+	case AssetOperationWithdraw, AssetOperationTransfer:
+		return a.WithdrawEnabled
+	}
+	return false
+}
+
+// ValidateAmount checks that amount is positive, within the asset's limits
+// and does not carry more fractional digits than the asset allows
+func (a *Asset) ValidateAmount(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return errors.New("amount must be greater than 0")
+	}
+
+	if !amount.Equal(amount.Truncate(a.Decimals)) {
+		return fmt.Errorf("amount exceeds %d decimal places allowed for %s", a.Decimals, a.Symbol)
+	}
+
+	if a.MinAmount.IsPositive() && amount.LessThan(a.MinAmount) {
+		return fmt.Errorf("minimum amount for %s is %s", a.Symbol, a.MinAmount)
+	}
+
+	if a.MaxAmount.IsPositive() && amount.GreaterThan(a.MaxAmount) {
+		return fmt.Errorf("maximum amount for %s is %s", a.Symbol, a.MaxAmount)
+	}
+
+	return nil
+}
+
+// Validate checks the asset definition itself, used by the admin API
+func (a *Asset) Validate() error {
+	if a.Symbol == "" || len(a.Symbol) > 10 {
+		return errors.New("symbol must be 1 to 10 characters")
+	}
+	if a.Decimals < 0 || a.Decimals > MaxAssetDecimals {
+		return fmt.Errorf("decimals must be between 0 and %d", MaxAssetDecimals)
+	}
+	if a.MinAmount.IsNegative() || a.MaxAmount.IsNegative() {
+		return errors.New("amount limits cannot be negative")
+	}
+	if a.MaxAmount.IsPositive() && a.MinAmount.GreaterThan(a.MaxAmount) {
+		return errors.New("min_amount cannot be greater than max_amount")
+	}
+	return nil
+}
+
+// AssetRequest is the admin payload for creating or updating an asset
+type AssetRequest struct {
+	Symbol          string          `json:"symbol"`
+	Name            string          `json:"name" binding:"required"`
+	ProviderID      string          `json:"provider_id"`
+	Decimals        int32           `json:"decimals"`
+	Enabled         *bool           `json:"enabled"`
+	DepositEnabled  *bool           `json:"deposit_enabled"`
+	WithdrawEnabled *bool           `json:"withdraw_enabled"`
+	MinAmount       decimal.Decimal `json:"min_amount"`
+	MaxAmount       decimal.Decimal `json:"max_amount"`
+}
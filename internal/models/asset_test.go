@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestAssetAllows(t *testing.T) {
+	tests := []struct {
+		name  string
+		asset Asset
+		op    AssetOperation
+		want  bool
+	}{
+		{"deposit enabled", Asset{Enabled: true, DepositEnabled: true}, AssetOperationDeposit, true},
+		{"deposit disabled", Asset{Enabled: true, WithdrawEnabled: true}, AssetOperationDeposit, false},
+		{"withdraw enabled", Asset{Enabled: true, WithdrawEnabled: true}, AssetOperationWithdraw, true},
+		{"withdraw disabled", Asset{Enabled: true, DepositEnabled: true}, AssetOperationWithdraw, false},
+		{"transfer follows withdraw", Asset{Enabled: true, WithdrawEnabled: true}, AssetOperationTransfer, true},
+		{"transfer blocked when withdraw disabled", Asset{Enabled: true, DepositEnabled: true}, AssetOperationTransfer, false},
+		{"asset disabled", Asset{DepositEnabled: true, WithdrawEnabled: true}, AssetOperationDeposit, false},
+		{"unknown operation", Asset{Enabled: true, DepositEnabled: true, WithdrawEnabled: true}, AssetOperation("swap"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.asset.Allows(tt.op); got != tt.want {
+				t.Errorf("Allows(%s) = %v, want %v", tt.op, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ImportMapping describes how the columns of an exchange's CSV export map
+// onto transactions. Column names are matched case-insensitively against the
+// header row.
+type ImportMapping struct {
+	Source         string                     `json:"source"`    // Prefix of ExternalRef, e.g. "generic"
+	Delimiter      string                     `json:"delimiter"` // Default ","
+	DateColumn     string                     `json:"date_column"`
+	TypeColumn     string                     `json:"type_column"`
+	CurrencyColumn string                     `json:"currency_column"`
+	AmountColumn   string                     `json:"amount_column"`
+	PriceColumn    string                     `json:"price_column,omitempty"` // Harga per unit dalam IDR
+	IDColumn       string                     `json:"id_column,omitempty"`    // Id transaksi di exchange asal
+	DateFormats    []string                   `json:"date_formats,omitempty"` // Layout Go, default RFC 3339 dan "2006-01-02 15:04:05"
+	Types          map[string]TransactionType `json:"types"`                  // Nilai kolom type (huruf kecil) -> deposit/withdraw
+}
+
+// ImportRow is one parsed CSV row as shown in a dry run
+type ImportRow struct {
+	Line        int             `json:"line"`
+	ExternalRef string          `json:"external_ref,omitempty"`
+	Date        time.Time       `json:"date"`
+	Type        TransactionType `json:"type,omitempty"`
+	Currency    string          `json:"currency,omitempty"`
+	Amount      decimal.Decimal `json:"amount"`
+	PriceIDR    decimal.Decimal `json:"price_idr"`
+	Duplicate   bool            `json:"duplicate,omitempty"` // Sudah pernah diimport, dilewati
+	Errors      []string        `json:"errors,omitempty"`
+}
+
+type ImportResult struct {
+	Source     string      `json:"source"`
+	DryRun     bool        `json:"dry_run"`
+	TotalRows  int         `json:"total_rows"`
+	ValidRows  int         `json:"valid_rows"`
+	Duplicates int         `json:"duplicates"`
+	ErrorRows  int         `json:"error_rows"`
+	Imported   int         `json:"imported"`
+	Rows       []ImportRow `json:"rows"`
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type LedgerAccountType string
+
+const (
+	LedgerAccountUserWallet LedgerAccountType = "user_wallet"
+	LedgerAccountClearing   LedgerAccountType = "system_clearing"
+	LedgerAccountFeeIncome  LedgerAccountType = "fee_income"
+)
+
+type JournalEntryType string
+
+const (
+	JournalEntryDeposit        JournalEntryType = "deposit"
+	JournalEntryWithdraw       JournalEntryType = "withdraw"
+	JournalEntryTransfer       JournalEntryType = "transfer"
+	JournalEntryFee            JournalEntryType = "fee"
+	JournalEntryOpeningBalance JournalEntryType = "opening_balance"
+	JournalEntryImport         JournalEntryType = "import"
+)
+
+// LedgerAccount is one account in the double-entry ledger. Every user wallet
+// has a matching user_wallet account; system accounts have no UserID.
+type LedgerAccount struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code      string            `gorm:"type:varchar(100);uniqueIndex;not null" json:"code"` // user:<id>:BTC, system_clearing:BTC
+	Type      LedgerAccountType `gorm:"type:varchar(30);not null" json:"type"`
+	UserID    *uuid.UUID        `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Currency  string            `gorm:"type:varchar(10);not null" json:"currency"`
+	CreatedAt time.Time         `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (a *LedgerAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// UserWalletAccount returns the ledger account backing a user's wallet
+func UserWalletAccount(userID uuid.UUID, currency string) LedgerAccount {
+	return LedgerAccount{
+		Code:     fmt.Sprintf("user:%s:%s", userID, currency),
+		Type:     LedgerAccountUserWallet,
+		UserID:   &userID,
+		Currency: currency,
+	}
+}
+
+// SystemAccount returns a per-currency system account such as clearing or fee income
+func SystemAccount(accountType LedgerAccountType, currency string) LedgerAccount {
+	return LedgerAccount{
+		Code:     fmt.Sprintf("%s:%s", accountType, currency),
+		Type:     accountType,
+		Currency: currency,
+	}
+}
+
+// JournalEntry is a balanced set of postings: the signed amounts of its
+// postings always sum to zero.
+type JournalEntry struct {
+	ID            uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type          JournalEntryType `gorm:"type:varchar(30);not null" json:"type"`
+	TransactionID *uuid.UUID       `gorm:"type:uuid;index" json:"transaction_id,omitempty"`
+	Description   string           `gorm:"type:varchar(255)" json:"description"`
+	CreatedAt     time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	Postings      []Posting        `gorm:"foreignKey:EntryID" json:"postings"`
+}
+
+func (e *JournalEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// Validate checks that the entry has postings and that they balance
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("journal entry needs at least two postings")
+	}
+
+	sum := decimal.Zero
+	for _, p := range e.Postings {
+		sum = sum.Add(p.Amount)
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("journal entry is unbalanced by %s", sum)
+	}
+
+	return nil
+}
+
+// Posting moves Amount into (positive) or out of (negative) one ledger account
+type Posting struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EntryID   uuid.UUID       `gorm:"type:uuid;not null;index" json:"entry_id"`
+	AccountID uuid.UUID       `gorm:"type:uuid;not null;index" json:"account_id"`
+	Amount    decimal.Decimal `gorm:"type:numeric(38,18);not null" json:"amount"`
+	CreatedAt time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (p *Posting) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// ComputedBalance is a user's balance in one currency computed from the
+// ledger postings or the transaction history rather than read from Wallet
+type ComputedBalance struct {
+	UserID   uuid.UUID       `json:"user_id"`
+	Currency string          `json:"currency"`
+	Balance  decimal.Decimal `json:"balance"`
+}
+
+// BalanceCorrection records a wallet whose cached balance was rewritten from the ledger
+type BalanceCorrection struct {
+	UserID          uuid.UUID       `json:"user_id"`
+	Currency        string          `json:"currency"`
+	PreviousBalance decimal.Decimal `json:"previous_balance"`
+	LedgerBalance   decimal.Decimal `json:"ledger_balance"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestJournalEntryValidate(t *testing.T) {
+	posting := func(amount string) Posting {
+		return Posting{Amount: decimal.RequireFromString(amount)}
+	}
+
+	tests := []struct {
+		name     string
+		postings []Posting
+		valid    bool
+	}{
+		{"balanced pair", []Posting{posting("100"), posting("-100")}, true},
+		{"balanced with fee", []Posting{posting("-100.5"), posting("100"), posting("0.5")}, true},
+		{"precision", []Posting{posting("0.000000000000000001"), posting("-0.000000000000000001")}, true},
+		{"unbalanced", []Posting{posting("100"), posting("-99.99")}, false},
+		{"single posting", []Posting{posting("0")}, false},
+		{"no postings", nil, false},
+	}
+
+	for _, tt := range tests {
+		entry := JournalEntry{Postings: tt.postings}
+		if err := entry.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// CostMethod selects how disposals are matched against earlier acquisitions
+type CostMethod string
+
+const (
+	CostMethodFIFO    CostMethod = "fifo"
+	CostMethodLIFO    CostMethod = "lifo"
+	CostMethodAverage CostMethod = "average"
+)
+
+// ParseCostMethod parses a cost method, defaulting to FIFO when empty
+func ParseCostMethod(value string) (CostMethod, error) {
+	switch method := CostMethod(strings.ToLower(strings.TrimSpace(value))); method {
+	case "":
+		return CostMethodFIFO, nil
+	case CostMethodFIFO, CostMethodLIFO, CostMethodAverage:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unsupported cost method %q, use fifo, lifo or average", value)
+	}
+}
+
+// AssetPnL is the cost basis and profit/loss of one currency, in IDR
+type AssetPnL struct {
+	Currency            string          `json:"currency"`
+	Quantity            decimal.Decimal `json:"quantity"`
+	CostBasisIDR        decimal.Decimal `json:"cost_basis_idr"`   // Total biaya perolehan unit yang masih dipegang
+	AverageCostIDR      decimal.Decimal `json:"average_cost_idr"` // Biaya perolehan rata-rata per unit
+	PriceIDR            decimal.Decimal `json:"price_idr"`
+	MarketValueIDR      decimal.Decimal `json:"market_value_idr"`
+	UnrealizedPnLIDR    decimal.Decimal `json:"unrealized_pnl_idr"`
+	RealizedPnLIDR      decimal.Decimal `json:"realized_pnl_idr"`
+	IncompleteCostBasis bool            `json:"incomplete_cost_basis,omitempty"` // Ada transaksi tanpa price_at atau pelepasan melebihi saldo
+}
+
+type PnLResponse struct {
+	Method                CostMethod      `json:"method"`
+	Assets                []AssetPnL      `json:"assets"`
+	TotalCostBasisIDR     decimal.Decimal `json:"total_cost_basis_idr"`
+	TotalUnrealizedPnLIDR decimal.Decimal `json:"total_unrealized_pnl_idr"`
+	TotalRealizedPnLIDR   decimal.Decimal `json:"total_realized_pnl_idr"`
+}
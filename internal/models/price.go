@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceQuote is a price together with when it was fetched. A stale quote is
+// the last known good price, served while the provider cannot be reached.
+type PriceQuote struct {
+	Price      decimal.Decimal `json:"price"`
+	FetchedAt  time.Time       `json:"fetched_at"`
+	Stale      bool            `json:"stale"`
+	AgeSeconds int64           `json:"age_seconds"`
+}
+
+// PriceRefresherStatus describes the last runs of the background price
+// refresher, as shown on the health endpoint
+type PriceRefresherStatus struct {
+	Enabled             bool       `json:"enabled"`
+	Interval            string     `json:"interval,omitempty"`
+	LastRunAt           *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	PricesRefreshed     int        `json:"prices_refreshed"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Skipped             bool       `json:"skipped,omitempty"` // Run terakhir dilewati karena instance lain sedang refresh
+	NextRunAt           *time.Time `json:"next_run_at,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// PriceHistory is an IDR price observed for a currency at a point in time.
+// A row is written for every currency each time fresh prices are fetched
+// from the price provider.
+type PriceHistory struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"-"`
+	Currency   string          `gorm:"type:varchar(10);not null;index:idx_price_history_currency_time,priority:1" json:"currency"`
+	PriceIDR   decimal.Decimal `gorm:"type:numeric(30,8);not null" json:"price_idr"`
+	Source     string          `gorm:"type:varchar(100)" json:"source"`
+	RecordedAt time.Time       `gorm:"not null;index:idx_price_history_currency_time,priority:2" json:"recorded_at"`
+}
+
+func (PriceHistory) TableName() string {
+	return "price_history"
+}
+
+func (p *PriceHistory) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// PricePoint is one recorded price in a price history response
+type PricePoint struct {
+	Timestamp time.Time       `json:"timestamp"`
+	PriceIDR  decimal.Decimal `json:"price_idr"`
+	Source    string          `json:"source"`
+}
+
+type PriceHistoryResponse struct {
+	Symbol   string       `json:"symbol"`
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Interval string       `json:"interval"`
+	Points   []PricePoint `json:"points"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// WalletDrift describes a wallet whose stored balance does not match the
+// balance recomputed from its transaction history
+type WalletDrift struct {
+	WalletID        uuid.UUID       `json:"wallet_id"`
+	UserID          uuid.UUID       `json:"user_id"`
+	Currency        string          `json:"currency"`
+	WalletBalance   decimal.Decimal `json:"wallet_balance"`
+	ComputedBalance decimal.Decimal `json:"computed_balance"`
+	Drift           decimal.Decimal `json:"drift"` // wallet_balance - computed_balance
+	Frozen          bool            `json:"frozen"`
+}
+
+type ReconciliationReport struct {
+	StartedAt      time.Time     `json:"started_at"`
+	FinishedAt     time.Time     `json:"finished_at"`
+	WalletsChecked int           `json:"wallets_checked"`
+	DriftCount     int           `json:"drift_count"`
+	AutoFreeze     bool          `json:"auto_freeze"`
+	Drifts         []WalletDrift `json:"drifts"`
+}
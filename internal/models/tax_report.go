@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TaxDisposal is one transaction that moved crypto out of the user's wallet
+// and is therefore taxable
+type TaxDisposal struct {
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	Date          time.Time       `json:"date"`
+	Type          TransactionType `json:"type"`
+	Currency      string          `json:"currency"`
+	Amount        decimal.Decimal `json:"amount"`
+	PriceIDR      decimal.Decimal `json:"price_idr"`
+	ValueIDR      decimal.Decimal `json:"value_idr"` // Nilai transaksi, dasar pengenaan pajak
+	CostBasisIDR  decimal.Decimal `json:"cost_basis_idr"`
+	GainIDR       decimal.Decimal `json:"gain_idr"`
+	PPhIDR        decimal.Decimal `json:"pph_idr"`
+	PPNIDR        decimal.Decimal `json:"ppn_idr"`
+	PriceMissing  bool            `json:"price_missing,omitempty"` // price_at kosong, nilai transaksi 0
+}
+
+// TaxSummary aggregates disposals for one month, one asset or the whole year
+type TaxSummary struct {
+	Key       string          `json:"key"` // "2025-01", "BTC" atau "total"
+	Disposals int             `json:"disposals"`
+	ValueIDR  decimal.Decimal `json:"value_idr"`
+	GainIDR   decimal.Decimal `json:"gain_idr"`
+	PPhIDR    decimal.Decimal `json:"pph_idr"`
+	PPNIDR    decimal.Decimal `json:"ppn_idr"`
+	TaxIDR    decimal.Decimal `json:"tax_idr"` // PPh + PPN
+}
+
+// Add includes a disposal in the summary
+func (s *TaxSummary) Add(d TaxDisposal) {
+	s.Disposals++
+	s.ValueIDR = s.ValueIDR.Add(d.ValueIDR)
+	s.GainIDR = s.GainIDR.Add(d.GainIDR)
+	s.PPhIDR = s.PPhIDR.Add(d.PPhIDR)
+	s.PPNIDR = s.PPNIDR.Add(d.PPNIDR)
+	s.TaxIDR = s.PPhIDR.Add(s.PPNIDR)
+}
+
+type TaxReport struct {
+	UserID          uuid.UUID       `json:"user_id"`
+	Year            int             `json:"year"`
+	Timezone        string          `json:"timezone"`
+	CostMethod      CostMethod      `json:"cost_method"`
+	PPhFinalPercent decimal.Decimal `json:"pph_final_percent"`
+	PPNPercent      decimal.Decimal `json:"ppn_percent"`
+	Disposals       []TaxDisposal   `json:"disposals"`
+	Monthly         []TaxSummary    `json:"monthly"`
+	PerAsset        []TaxSummary    `json:"per_asset"`
+	Total           TaxSummary      `json:"total"`
+	MissingPrices   int             `json:"missing_prices"` // Jumlah disposal tanpa price_at
+	GeneratedAt     time.Time       `json:"generated_at"`
+}
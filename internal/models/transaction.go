@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -13,17 +14,44 @@ const (
 	TransactionTypeDeposit  TransactionType = "deposit"
 	TransactionTypeWithdraw TransactionType = "withdraw"
 	TransactionTypeTransfer TransactionType = "transfer"
+	TransactionTypeFee      TransactionType = "fee"
+)
+
+// TransactionStatus is the processing state of a transaction. Every money
+// movement is settled synchronously and is completed; rows brought in from
+// another exchange are imported and never count towards a balance.
+type TransactionStatus string
+
+const (
+	TransactionStatusCompleted TransactionStatus = "completed"
+	TransactionStatusImported  TransactionStatus = "imported"
+)
+
+// TransactionDirection tells whether a transaction row moved funds into or out
+// of the owner's wallet. Deposits are always credits and withdrawals debits;
+// transfers produce one row of each.
+type TransactionDirection string
+
+const (
+	TransactionDirectionCredit TransactionDirection = "credit"
+	TransactionDirectionDebit  TransactionDirection = "debit"
 )
 
 type Transaction struct {
-	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
-	Type      TransactionType `gorm:"type:varchar(20);not null" json:"type"`
-	Currency  string          `gorm:"type:varchar(10);not null" json:"currency"`
-	Amount    float64         `gorm:"type:numeric(18,8);not null" json:"amount"`
-	PriceAt   float64         `gorm:"type:numeric(18,2)" json:"price_at"` // Harga crypto saat transaksi (dalam IDR)
-	CreatedAt time.Time       `gorm:"autoCreateTime" json:"created_at"`
-	User      User            `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID             uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID            `gorm:"type:uuid;not null;index;index:idx_transactions_user_created,priority:1;uniqueIndex:idx_transactions_user_external_ref,priority:1" json:"user_id"`
+	Type           TransactionType      `gorm:"type:varchar(20);not null" json:"type"`
+	Direction      TransactionDirection `gorm:"type:varchar(10);not null;default:''" json:"direction,omitempty"`
+	Currency       string               `gorm:"type:varchar(10);not null" json:"currency"`
+	Amount         decimal.Decimal      `gorm:"type:numeric(38,18);not null" json:"amount"`
+	Status         TransactionStatus    `gorm:"type:varchar(20);not null;default:completed" json:"status"`
+	PriceAt        decimal.Decimal      `gorm:"type:numeric(30,8)" json:"price_at"`               // Harga crypto saat transaksi (dalam IDR)
+	CounterpartyID *uuid.UUID           `gorm:"type:uuid;index" json:"counterparty_id,omitempty"` // User lawan transaksi (transfer)
+	ReferenceID    *uuid.UUID           `gorm:"type:uuid;index" json:"reference_id,omitempty"`    // Menghubungkan baris debit/credit satu transfer
+	// Sumber:id untuk transaksi hasil import, mencegah baris yang sama diimport dua kali
+	ExternalRef *string   `gorm:"type:varchar(255);uniqueIndex:idx_transactions_user_external_ref,priority:2" json:"external_ref,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index:idx_transactions_user_created,priority:2" json:"created_at"`
+	User        User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
 
@@ -35,7 +63,61 @@ func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
 }
 
 
+// AffectsBalance reports whether the transaction moved the owner's wallet
+// balance. Imported history does not.
+func (t *Transaction) AffectsBalance() bool {
+	return t.Status != TransactionStatusImported
+}
+
+
+// SignedAmount returns the amount as it affected the owner's balance: positive
+// for credits and negative for debits. Rows written before Direction existed
+// fall back to their type.
+func (t *Transaction) SignedAmount() decimal.Decimal {
+	switch t.Direction {
+	case TransactionDirectionCredit:
+		return t.Amount
+	case TransactionDirectionDebit:
+		return t.Amount.Neg()
+	}
+
+	if t.Type == TransactionTypeDeposit {
+		return t.Amount
+	}
+	return t.Amount.Neg()
+}
+
+
 type TransactionRequest struct {
-	Currency string  `json:"currency" binding:"required"`
-	Amount   float64 `json:"amount" binding:"required,gt=0"`
+	Currency string          `json:"currency" binding:"required"`
+	Amount   decimal.Decimal `json:"amount"`
+	TOTPCode string          `json:"totp_code,omitempty"` // wajib untuk withdraw di atas batas 2FA
+}
+
+
+// TransferRequest moves funds to another user identified by email or user ID.
+type TransferRequest struct {
+	Recipient string          `json:"recipient" binding:"required"`
+	Currency  string          `json:"currency" binding:"required"`
+	Amount    decimal.Decimal `json:"amount"`
+	TOTPCode  string          `json:"totp_code,omitempty"` // wajib untuk transfer di atas batas 2FA
+}
+
+
+// TransactionCounterparty identifies the other user of a transfer
+type TransactionCounterparty struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+
+// TransactionDetail is a single transaction with its IDR valuation, the
+// owner's balance after it and the entries linked to it
+type TransactionDetail struct {
+	Transaction
+	ValueIDR     decimal.Decimal          `json:"value_idr"`
+	BalanceAfter decimal.Decimal          `json:"balance_after"`
+	Counterparty *TransactionCounterparty `json:"counterparty,omitempty"`
+	Related      []Transaction            `json:"related"`        // Baris lain dengan reference_id yang sama, mis. fee withdraw
+	User         *struct{}                `json:"user,omitempty"` // hide the embedded User relation
 }
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TransactionFilter narrows a user's transaction history. Empty fields do
+// not filter.
+type TransactionFilter struct {
+	Types      []TransactionType
+	Currencies []string
+	From       *time.Time // inclusive
+	To         *time.Time // exclusive
+	MinAmount  *decimal.Decimal
+	MaxAmount  *decimal.Decimal
+}
+
+const (
+	TransactionSortCreatedAt = "created_at"
+	TransactionSortAmount    = "amount"
+)
+
+// TransactionQuery is a filtered, sorted page of transactions. When Cursor is
+// set the page starts right after the cursor row (keyset pagination) and
+// Offset is ignored.
+type TransactionQuery struct {
+	Filter TransactionFilter
+	SortBy string // created_at or amount, ties are broken by id
+	Desc   bool
+	Limit  int
+	Offset int
+	Cursor *TransactionCursor
+}
+
+// TransactionCursor points at the last row of a page. It is handed to clients
+// as an opaque string and is only valid for the sort it was created with.
+type TransactionCursor struct {
+	SortBy string    `json:"s"`
+	Desc   bool      `json:"d"`
+	Value  string    `json:"v"`
+	ID     uuid.UUID `json:"i"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// NewTransactionCursor builds the cursor pointing at the given row
+func NewTransactionCursor(t Transaction, sortBy string, desc bool) TransactionCursor {
+	value := t.CreatedAt.UTC().Format(time.RFC3339Nano)
+	if sortBy == TransactionSortAmount {
+		value = t.Amount.String()
+	}
+	return TransactionCursor{SortBy: sortBy, Desc: desc, Value: value, ID: t.ID}
+}
+
+func (c TransactionCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeTransactionCursor parses a cursor produced by Encode
+func DecodeTransactionCursor(value string) (*TransactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor TransactionCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+
+	switch cursor.SortBy {
+	case TransactionSortCreatedAt:
+		_, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case TransactionSortAmount:
+		_, err = decimal.NewFromString(cursor.Value)
+	default:
+		err = ErrInvalidCursor
+	}
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
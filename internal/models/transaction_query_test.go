@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestTransactionCursorRoundTrip(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	tx := Transaction{
+		ID:        uuid.New(),
+		Amount:    decimal.RequireFromString("0.000123450000000001"),
+		CreatedAt: time.Date(2025, 3, 10, 9, 30, 15, 123456000, wib),
+	}
+
+	tests := []struct {
+		sortBy string
+		desc   bool
+		value  string
+	}{
+		{TransactionSortCreatedAt, true, "2025-03-10T02:30:15.123456Z"},
+		{TransactionSortCreatedAt, false, "2025-03-10T02:30:15.123456Z"},
+		{TransactionSortAmount, true, "0.000123450000000001"},
+		{TransactionSortAmount, false, "0.000123450000000001"},
+	}
+
+	for _, tt := range tests {
+		cursor := NewTransactionCursor(tx, tt.sortBy, tt.desc)
+		decoded, err := DecodeTransactionCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("%s desc=%v: %v", tt.sortBy, tt.desc, err)
+		}
+		if *decoded != cursor {
+			t.Errorf("%s desc=%v: decoded %+v, want %+v", tt.sortBy, tt.desc, *decoded, cursor)
+		}
+		if decoded.Value != tt.value {
+			t.Errorf("%s desc=%v: value = %s, want %s", tt.sortBy, tt.desc, decoded.Value, tt.value)
+		}
+	}
+}
+
+func TestDecodeTransactionCursorRejectsInvalid(t *testing.T) {
+	encode := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+	id := uuid.NewString()
+
+	tests := map[string]string{
+		"not base64":          "%%%",
+		"not json":            encode("cursor"),
+		"missing id":          encode(`{"s":"created_at","d":true,"v":"2025-03-10T02:30:15Z"}`),
+		"unknown sort":        encode(`{"s":"currency","d":true,"v":"BTC","i":"` + id + `"}`),
+		"bad time":            encode(`{"s":"created_at","d":true,"v":"yesterday","i":"` + id + `"}`),
+		"bad amount":          encode(`{"s":"amount","d":true,"v":"1e","i":"` + id + `"}`),
+		"time for amount":     encode(`{"s":"amount","d":true,"v":"2025-03-10T02:30:15Z","i":"` + id + `"}`),
+		"amount for time":     encode(`{"s":"created_at","d":true,"v":"100","i":"` + id + `"}`),
+		"padded base64 input": base64.URLEncoding.EncodeToString([]byte(`{"s":"amount","v":"1","i":"` + id + `"}`)),
+	}
+
+	for name, value := range tests {
+		if cursor, err := DecodeTransactionCursor(value); err != ErrInvalidCursor {
+			t.Errorf("%s: got %+v, %v, want ErrInvalidCursor", name, cursor, err)
+		}
+	}
+}
@@ -7,13 +7,30 @@ import (
 	"gorm.io/gorm"
 )
 
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
 type User struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
 	Email     string    `gorm:"type:varchar(100);unique;not null" json:"email"`
 	Password  string    `gorm:"type:varchar(255);not null" json:"-"`
+	Role      UserRole  `gorm:"type:varchar(20);not null;default:user" json:"role"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	Wallets   []Wallet  `gorm:"foreignKey:UserID" json:"wallets,omitempty"`
+
+	// TOTP two-factor authentication. The secret is set when enrollment
+	// starts and only used for login once TOTPEnabled is confirmed. It is
+	// stored encrypted with AES-256-GCM.
+	TOTPSecret   string `gorm:"type:text" json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"-"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"` // Time step kode terakhir yang dipakai, mencegah replay
+
+	EmailVerifiedAt *time.Time `json:"-"`
 }
 
 
@@ -26,18 +43,27 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               uuid.UUID `json:"id"`
+	Name             string    `json:"name"`
+	Email            string    `json:"email"`
+	EmailVerified    bool      `json:"email_verified"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
 }
 
 
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		Name:      u.Name,
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
+		ID:               u.ID,
+		Name:             u.Name,
+		Email:            u.Email,
+		EmailVerified:    u.EmailVerifiedAt != nil,
+		TwoFactorEnabled: u.TOTPEnabled,
+		CreatedAt:        u.CreatedAt,
 	}
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Purposes of single-use tokens sent to a user by email
+const (
+	UserTokenPurposeEmailVerification = "email_verification"
+	UserTokenPurposePasswordReset     = "password_reset"
+)
+
+// UserToken is a single-use, expiring token mailed to a user to verify the
+// email address or reset the password. Only the SHA-256 hash is stored.
+type UserToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"-"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_user_tokens_user_purpose,priority:1" json:"-"`
+	Purpose   string     `gorm:"type:varchar(30);not null;index:idx_user_tokens_user_purpose,priority:2" json:"-"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"-"`
+}
+
+func (t *UserToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
@@ -4,16 +4,18 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Wallet struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
-	Currency  string    `gorm:"type:varchar(10);not null" json:"currency"` // BTC, ETH, USDT, IDR
-	Balance   float64   `gorm:"type:numeric(18,8);not null;default:0" json:"balance"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_wallets_user_currency" json:"user_id"`
+	Currency  string          `gorm:"type:varchar(10);not null;uniqueIndex:idx_wallets_user_currency" json:"currency"` // BTC, ETH, USDT, IDR
+	Balance   decimal.Decimal `gorm:"type:numeric(38,18);not null;default:0" json:"balance"`
+	Frozen    bool            `gorm:"not null;default:false" json:"frozen"` // Dibekukan oleh reconciliation/admin
+	UpdatedAt time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+	User      User            `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
 
@@ -26,14 +28,35 @@ func (w *Wallet) BeforeCreate(tx *gorm.DB) error {
 
 
 type WalletWithPrice struct {
-	Currency string  `json:"currency"`
-	Balance  float64 `json:"balance"`
-	PriceIDR float64 `json:"price_idr"`
-	ValueIDR float64 `json:"value_idr"`
+	Currency        string          `json:"currency"`
+	Balance         decimal.Decimal `json:"balance"`
+	PriceIDR        decimal.Decimal `json:"price_idr"`
+	ValueIDR        decimal.Decimal `json:"value_idr"`
+	PriceStale      bool            `json:"price_stale,omitempty"`       // Harga terakhir yang diketahui, provider sedang tidak tersedia
+	PriceAgeSeconds int64           `json:"price_age_seconds,omitempty"` // Umur harga stale
 }
 
 
 type PortfolioResponse struct {
 	Assets        []WalletWithPrice `json:"assets"`
-	TotalValueIDR float64           `json:"total_value_idr"`
+	TotalValueIDR decimal.Decimal   `json:"total_value_idr"`
+	PricesStale   bool              `json:"prices_stale"`
+	PnL           *PnLResponse      `json:"pnl,omitempty"`
+}
+
+
+// PortfolioHistoryPoint is the portfolio valued at the end of one interval
+type PortfolioHistoryPoint struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	Assets        []WalletWithPrice `json:"assets"`
+	TotalValueIDR decimal.Decimal   `json:"total_value_idr"`
+	MissingPrices []string          `json:"missing_prices,omitempty"` // Currency tanpa harga historis, dinilai 0
+}
+
+
+type PortfolioHistoryResponse struct {
+	From     time.Time               `json:"from"`
+	To       time.Time               `json:"to"`
+	Interval string                  `json:"interval"`
+	Points   []PortfolioHistoryPoint `json:"points"`
 }
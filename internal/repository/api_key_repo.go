@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	FindByHash(hash string) (*models.APIKey, error)
+	FindByID(id uuid.UUID) (*models.APIKey, error)
+	FindByUserID(userID uuid.UUID) ([]models.APIKey, error)
+	CountActive(userID uuid.UUID) (int64, error)
+	Revoke(userID, id uuid.UUID) error
+	RevokeAllForUser(userID uuid.UUID) (int64, error)
+	TouchLastUsed(id uuid.UUID, at time.Time, every time.Duration) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *apiKeyRepository) FindByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("key_hash = ?", hash).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindByID(id uuid.UUID) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("id = ?", id).First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindByUserID returns all keys of the user, including revoked ones, newest first
+func (r *apiKeyRepository) FindByUserID(userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// CountActive counts the keys that are not revoked or expired
+func (r *apiKeyRepository) CountActive(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now().UTC()).
+		Count(&count).Error
+	return count, err
+}
+
+// Revoke revokes one of the user's keys. Keys of other users are reported
+// as not found.
+func (r *apiKeyRepository) Revoke(userID, id uuid.UUID) error {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every active key of the user and returns how many
+// were revoked
+func (r *apiKeyRepository) RevokeAllForUser(userID uuid.UUID) (int64, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC())
+	return result.RowsAffected, result.Error
+}
+
+// TouchLastUsed records that the key was used, at most once per `every` so
+// busy bots do not cause a write on every request
+func (r *apiKeyRepository) TouchLastUsed(id uuid.UUID, at time.Time, every time.Duration) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-every)).
+		Update("last_used_at", at).Error
+}
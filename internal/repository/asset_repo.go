@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type AssetRepository interface {
+	Create(asset *models.Asset) error
+	FindAll() ([]models.Asset, error)
+	FindBySymbol(symbol string) (*models.Asset, error)
+	Update(asset *models.Asset) error
+	Count() (int64, error)
+}
+
+type assetRepository struct {
+	db *gorm.DB
+}
+
+func NewAssetRepository(db *gorm.DB) AssetRepository {
+	return &assetRepository{db: db}
+}
+
+func (r *assetRepository) Create(asset *models.Asset) error {
+	return r.db.Create(asset).Error
+}
+
+func (r *assetRepository) FindAll() ([]models.Asset, error) {
+	var assets []models.Asset
+	err := r.db.Order("symbol").Find(&assets).Error
+	if err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+func (r *assetRepository) FindBySymbol(symbol string) (*models.Asset, error) {
+	var asset models.Asset
+	err := r.db.Where("symbol = ?", symbol).First(&asset).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("asset not found")
+		}
+		return nil, err
+	}
+	return &asset, nil
+}
+
+func (r *assetRepository) Update(asset *models.Asset) error {
+	return r.db.Save(asset).Error
+}
+
+func (r *assetRepository) Count() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Asset{}).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LedgerRepository interface {
+	FindOrCreateAccount(account models.LedgerAccount) (*models.LedgerAccount, error)
+	CreateEntry(entry *models.JournalEntry) error
+	HasPostings(accountID uuid.UUID) (bool, error)
+	AccountBalance(code string) (decimal.Decimal, error)
+	UserWalletBalances() ([]models.ComputedBalance, error)
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// FindOrCreateAccount returns the account with the given code, creating it if needed
+func (r *ledgerRepository) FindOrCreateAccount(account models.LedgerAccount) (*models.LedgerAccount, error) {
+	if err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoNothing: true,
+	}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+
+	var existing models.LedgerAccount
+	if err := r.db.Where("code = ?", account.Code).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// CreateEntry stores the entry together with its postings. Callers should
+// validate the entry first.
+func (r *ledgerRepository) CreateEntry(entry *models.JournalEntry) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *ledgerRepository) HasPostings(accountID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Posting{}).Where("account_id = ?", accountID).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+// AccountBalance sums the postings of one account, zero when the account
+// does not exist
+func (r *ledgerRepository) AccountBalance(code string) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := r.db.Table("postings AS p").
+		Select("COALESCE(SUM(p.amount), 0)").
+		Joins("JOIN ledger_accounts a ON a.id = p.account_id").
+		Where("a.code = ?", code).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// UserWalletBalances sums postings for every user wallet account
+func (r *ledgerRepository) UserWalletBalances() ([]models.ComputedBalance, error) {
+	var balances []models.ComputedBalance
+	err := r.db.Table("ledger_accounts AS a").
+		Select("a.user_id, a.currency, COALESCE(SUM(p.amount), 0) AS balance").
+		Joins("LEFT JOIN postings p ON p.account_id = a.id").
+		Where("a.type = ?", models.LedgerAccountUserWallet).
+		Group("a.user_id, a.currency").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PriceHistoryRepository interface {
+	CreateBatch(prices []models.PriceHistory) error
+	FindLatestBefore(currency string, at time.Time) (*models.PriceHistory, error)
+	FindLastPerBucket(currencies []string, from, to time.Time, bucket time.Duration) ([]models.PriceHistory, error)
+	FindRecordedTimes(currency, source string, from, to time.Time) ([]time.Time, error)
+}
+
+type priceHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceHistoryRepository(db *gorm.DB) PriceHistoryRepository {
+	return &priceHistoryRepository{db: db}
+}
+
+func (r *priceHistoryRepository) CreateBatch(prices []models.PriceHistory) error {
+	if len(prices) == 0 {
+		return nil
+	}
+	return r.db.Create(&prices).Error
+}
+
+// FindLatestBefore returns the last price recorded at or before the given
+// time, or nil when there is none
+func (r *priceHistoryRepository) FindLatestBefore(currency string, at time.Time) (*models.PriceHistory, error) {
+	var price models.PriceHistory
+	err := r.db.Where("currency = ? AND recorded_at <= ?", currency, at).
+		Order("recorded_at DESC").
+		First(&price).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &price, nil
+}
+
+// FindLastPerBucket splits [from, to) into buckets of the given size counted
+// from `from` and returns the last price recorded for each currency in every
+// bucket, oldest first. This keeps long ranges cheap even though prices are
+// recorded every few minutes.
+func (r *priceHistoryRepository) FindLastPerBucket(currencies []string, from, to time.Time, bucket time.Duration) ([]models.PriceHistory, error) {
+	// The bucket number is computed once so DISTINCT ON and ORDER BY refer
+	// to the same column
+	var prices []models.PriceHistory
+	err := r.db.Raw(`WITH bucketed AS (
+			SELECT *, FLOOR(EXTRACT(EPOCH FROM recorded_at - ?::timestamptz) / ?) AS bucket
+			FROM price_history
+			WHERE currency IN ? AND recorded_at >= ? AND recorded_at < ?
+		)
+		SELECT id, currency, price_idr, source, recorded_at FROM (
+			SELECT DISTINCT ON (currency, bucket) *
+			FROM bucketed
+			ORDER BY currency, bucket, recorded_at DESC
+		) AS last_per_bucket
+		ORDER BY recorded_at ASC, currency ASC`,
+		from, bucket.Seconds(), currencies, from, to,
+	).Scan(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// FindRecordedTimes returns when prices from the given source were recorded
+// for a currency in [from, to), so a backfill can skip what it already loaded
+func (r *priceHistoryRepository) FindRecordedTimes(currency, source string, from, to time.Time) ([]time.Time, error) {
+	var times []time.Time
+	err := r.db.Model(&models.PriceHistory{}).
+		Where("currency = ? AND source = ? AND recorded_at >= ? AND recorded_at < ?", currency, source, from, to).
+		Pluck("recorded_at", &times).Error
+	if err != nil {
+		return nil, err
+	}
+	return times, nil
+}
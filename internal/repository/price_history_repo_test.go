@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestPriceHistoryLastPerBucket(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewPriceHistoryRepository(db)
+
+	// Currencies unique to this run keep earlier rows out of the result
+	btc := "B" + strings.ToUpper(uuid.NewString()[:8])
+	eth := "E" + strings.ToUpper(uuid.NewString()[:8])
+	from := time.Date(2025, 3, 10, 0, 30, 0, 0, time.UTC)
+
+	price := func(currency string, offset time.Duration, value int64) models.PriceHistory {
+		return models.PriceHistory{
+			Currency:   currency,
+			PriceIDR:   decimal.NewFromInt(value),
+			Source:     "test",
+			RecordedAt: from.Add(offset),
+		}
+	}
+	err := repo.CreateBatch([]models.PriceHistory{
+		price(btc, -time.Minute, 1),             // before the range
+		price(btc, 0, 10),                       // bucket 0
+		price(btc, 59*time.Minute, 11),          // bucket 0, last
+		price(btc, time.Hour, 20),               // bucket 1, last
+		price(btc, 3*time.Hour+time.Minute, 40), // bucket 3, last
+		price(eth, 10*time.Minute, 100),         // bucket 0
+		price(eth, 30*time.Minute, 101),         // bucket 0, last
+		price(eth, 4*time.Hour, 500),            // at `to`, excluded
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prices, err := repo.FindLastPerBucket([]string{btc, eth}, from, from.Add(4*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		currency string
+		value    int64
+	}{
+		{eth, 101},
+		{btc, 11},
+		{btc, 20},
+		{btc, 40},
+	}
+	if len(prices) != len(want) {
+		t.Fatalf("got %d prices %+v, want %d", len(prices), prices, len(want))
+	}
+	for i, w := range want {
+		if prices[i].Currency != w.currency || !prices[i].PriceIDR.Equal(decimal.NewFromInt(w.value)) {
+			t.Errorf("price %d = %s %s, want %s %d", i, prices[i].Currency, prices[i].PriceIDR, w.currency, w.value)
+		}
+	}
+}
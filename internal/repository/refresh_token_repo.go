@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(hash string) (*models.RefreshToken, error)
+	Rotate(currentID uuid.UUID, next *models.RefreshToken) (bool, error)
+	RevokeFamily(familyID uuid.UUID) error
+	RevokeAllForUser(userID uuid.UUID) ([]uuid.UUID, error)
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token *models.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *refreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("refresh token not found")
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// errAlreadyRotated rolls back a rotation that lost to a concurrent refresh
+var errAlreadyRotated = errors.New("refresh token already rotated")
+
+// Rotate stores the successor and revokes the current token in its favour in
+// one database transaction, inserting first so a failed insert never leaves
+// the current token revoked. It returns false, storing nothing, when the
+// current token had already been revoked, e.g. by a concurrent refresh.
+func (r *refreshTokenRepository) Rotate(currentID uuid.UUID, next *models.RefreshToken) (bool, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", currentID).
+			Updates(map[string]interface{}{
+				"revoked_at":     time.Now().UTC(),
+				"replaced_by_id": next.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errAlreadyRotated
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyRotated) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID uuid.UUID) error {
+	return r.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now().UTC()).Error
+}
+
+// RevokeAllForUser revokes every active refresh token of the user and returns
+// the affected family IDs. Revoking and collecting happen in one statement,
+// so a token issued meanwhile is either revoked and reported or untouched.
+func (r *refreshTokenRepository) RevokeAllForUser(userID uuid.UUID) ([]uuid.UUID, error) {
+	var tokens []models.RefreshToken
+	err := r.db.Model(&tokens).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "family_id"}}}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(tokens))
+	familyIDs := make([]uuid.UUID, 0, len(tokens))
+	for _, token := range tokens {
+		if !seen[token.FamilyID] {
+			seen[token.FamilyID] = true
+			familyIDs = append(familyIDs, token.FamilyID)
+		}
+	}
+	return familyIDs, nil
+}
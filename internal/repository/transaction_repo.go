@@ -2,16 +2,31 @@ package repository
 
 import (
 	"crypto-wallet-service/internal/models"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
+var ErrTransactionNotFound = errors.New("transaction not found")
+
 type TransactionRepository interface {
 	Create(transaction *models.Transaction) error
 	FindByUserID(userID uuid.UUID, limit, offset int) ([]models.Transaction, error)
 	FindByID(id uuid.UUID) (*models.Transaction, error)
 	CountByUserID(userID uuid.UUID) (int64, error)
+	Search(userID uuid.UUID, query models.TransactionQuery) ([]models.Transaction, error)
+	CountFiltered(userID uuid.UUID, filter models.TransactionFilter) (int64, error)
+	FindByUserIDUntil(userID uuid.UUID, until time.Time) ([]models.Transaction, error)
+	SumBalances() ([]models.ComputedBalance, error)
+	SumUserBalance(userID uuid.UUID, currency string) (decimal.Decimal, error)
+	SumUserBalancesBefore(userID uuid.UUID, before time.Time) ([]models.ComputedBalance, error)
+	StreamByUserID(userID uuid.UUID, filter models.TransactionFilter, fn func(models.Transaction) error) error
+	FindExternalRefs(userID uuid.UUID, refs []string) ([]string, error)
+	FindByReferenceID(userID uuid.UUID, referenceID uuid.UUID) ([]models.Transaction, error)
+	BalanceAfter(transaction *models.Transaction) (decimal.Decimal, error)
 }
 
 type transactionRepository struct {
@@ -46,6 +61,9 @@ func (r *transactionRepository) FindByID(id uuid.UUID) (*models.Transaction, err
 	var transaction models.Transaction
 	err := r.db.Where("id = ?", id).First(&transaction).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransactionNotFound
+		}
 		return nil, err
 	}
 	return &transaction, nil
@@ -56,3 +74,208 @@ func (r *transactionRepository) CountByUserID(userID uuid.UUID) (int64, error) {
 	err := r.db.Model(&models.Transaction{}).Where("user_id = ?", userID).Count(&count).Error
 	return count, err
 }
+
+// Search returns one page of the user's transactions matching the query
+func (r *transactionRepository) Search(userID uuid.UUID, query models.TransactionQuery) ([]models.Transaction, error) {
+	column := "created_at"
+	if query.SortBy == models.TransactionSortAmount {
+		column = "amount"
+	}
+	direction, comparison := "ASC", ">"
+	if query.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	db := applyTransactionFilter(r.db.Where("user_id = ?", userID), query.Filter)
+
+	if query.Cursor != nil {
+		// Row comparison keeps the page stable while new rows are inserted
+		db = db.Where("("+column+", id) "+comparison+" (?, ?)", query.Cursor.Value, query.Cursor.ID)
+	} else if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var transactions []models.Transaction
+	err := db.Order(column + " " + direction).Order("id " + direction).Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *transactionRepository) CountFiltered(userID uuid.UUID, filter models.TransactionFilter) (int64, error) {
+	var count int64
+	db := applyTransactionFilter(r.db.Model(&models.Transaction{}).Where("user_id = ?", userID), filter)
+	err := db.Count(&count).Error
+	return count, err
+}
+
+func applyTransactionFilter(db *gorm.DB, filter models.TransactionFilter) *gorm.DB {
+	if len(filter.Types) > 0 {
+		db = db.Where("type IN ?", filter.Types)
+	}
+	if len(filter.Currencies) > 0 {
+		db = db.Where("currency IN ?", filter.Currencies)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+	if filter.MinAmount != nil {
+		db = db.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		db = db.Where("amount <= ?", *filter.MaxAmount)
+	}
+	return db
+}
+
+// FindExternalRefs returns which of the given external references the user
+// already has transactions for
+func (r *transactionRepository) FindExternalRefs(userID uuid.UUID, refs []string) ([]string, error) {
+	var existing []string
+	if len(refs) == 0 {
+		return existing, nil
+	}
+
+	err := r.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND external_ref IN ?", userID, refs).
+		Pluck("external_ref", &existing).Error
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// FindByReferenceID returns the user's transactions linked by a reference
+// ID, such as a withdrawal and its fee
+func (r *transactionRepository) FindByReferenceID(userID uuid.UUID, referenceID uuid.UUID) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.Where("user_id = ? AND reference_id = ?", userID, referenceID).
+		Order("created_at ASC, id ASC").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// BalanceAfter returns the owner's balance in the transaction's currency
+// right after it, from all of their transactions up to and including it
+func (r *transactionRepository) BalanceAfter(transaction *models.Transaction) (decimal.Decimal, error) {
+	var balances []models.ComputedBalance
+	err := r.sumBalances(r.db.Model(&models.Transaction{})).
+		Where("user_id = ? AND currency = ?", transaction.UserID, transaction.Currency).
+		Where("(created_at, id) <= (?, ?)", transaction.CreatedAt, transaction.ID).
+		Group("user_id, currency").
+		Scan(&balances).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if len(balances) == 0 {
+		return decimal.Zero, nil
+	}
+	return balances[0].Balance, nil
+}
+
+// FindByUserIDUntil returns every transaction of the user created at or
+// before until, oldest first
+func (r *transactionRepository) FindByUserIDUntil(userID uuid.UUID, until time.Time) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.Where("user_id = ? AND created_at <= ?", userID, until).
+		Order("created_at ASC").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// SumBalances recomputes every user's balance per currency from the
+// transaction history: credits minus debits, without imported rows. Rows without a direction are
+// treated as credits when they are deposits and debits otherwise.
+func (r *transactionRepository) SumBalances() ([]models.ComputedBalance, error) {
+	var balances []models.ComputedBalance
+	err := r.sumBalances(r.db.Model(&models.Transaction{})).
+		Group("user_id, currency").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// SumUserBalance recomputes the balance of one wallet from its transactions
+func (r *transactionRepository) SumUserBalance(userID uuid.UUID, currency string) (decimal.Decimal, error) {
+	var balances []models.ComputedBalance
+	err := r.sumBalances(r.db.Model(&models.Transaction{})).
+		Where("user_id = ? AND currency = ?", userID, currency).
+		Group("user_id, currency").
+		Scan(&balances).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if len(balances) == 0 {
+		return decimal.Zero, nil
+	}
+	return balances[0].Balance, nil
+}
+
+// SumUserBalancesBefore returns the user's balance per currency from all
+// transactions created before the given time
+func (r *transactionRepository) SumUserBalancesBefore(userID uuid.UUID, before time.Time) ([]models.ComputedBalance, error) {
+	var balances []models.ComputedBalance
+	err := r.sumBalances(r.db.Model(&models.Transaction{})).
+		Where("user_id = ? AND created_at < ?", userID, before).
+		Group("user_id, currency").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// sumBalances selects the balance per user and currency. Imported rows are
+// history only and are left out.
+func (r *transactionRepository) sumBalances(db *gorm.DB) *gorm.DB {
+	return db.Where("status <> ?", models.TransactionStatusImported).Select(`user_id, currency, COALESCE(SUM(CASE
+			WHEN direction = ? THEN amount
+			WHEN direction = ? THEN -amount
+			WHEN type = ? THEN amount
+			ELSE -amount
+		END), 0) AS balance`,
+		models.TransactionDirectionCredit,
+		models.TransactionDirectionDebit,
+		models.TransactionTypeDeposit,
+	)
+}
+
+// StreamByUserID calls fn for every matching transaction of the user, ordered
+// by currency and then oldest first, reading rows one at a time instead of
+// loading the whole history into memory. Iteration stops at the first error
+// returned by fn.
+func (r *transactionRepository) StreamByUserID(userID uuid.UUID, filter models.TransactionFilter, fn func(models.Transaction) error) error {
+	rows, err := applyTransactionFilter(r.db.Model(&models.Transaction{}).Where("user_id = ?", userID), filter).
+		Order("currency ASC, created_at ASC, id ASC").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := r.db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TestTransactionSearchKeysetTies pages through rows that share created_at
+// and amount and checks that the id tie-breaker neither skips nor repeats a
+// row in any sort order
+func TestTransactionSearchKeysetTies(t *testing.T) {
+	db := openTestDB(t)
+	users := NewUserRepository(db)
+	transactions := NewTransactionRepository(db)
+
+	user := &models.User{Name: "Test User", Email: uuid.NewString() + "@example.com", Password: "x"}
+	if err := users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Postgres keeps microseconds, so the cursor value must round trip exactly
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	var ids []uuid.UUID
+	for i := 0; i < 7; i++ {
+		tx := &models.Transaction{
+			UserID:    user.ID,
+			Type:      models.TransactionTypeDeposit,
+			Direction: models.TransactionDirectionCredit,
+			Currency:  "IDR",
+			Amount:    decimal.NewFromInt(100),
+			Status:    models.TransactionStatusCompleted,
+			CreatedAt: createdAt,
+		}
+		if err := transactions.Create(tx); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, tx.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	for _, sortBy := range []string{models.TransactionSortCreatedAt, models.TransactionSortAmount} {
+		for _, desc := range []bool{false, true} {
+			query := models.TransactionQuery{SortBy: sortBy, Desc: desc, Limit: 3}
+
+			var seen []uuid.UUID
+			for page := 0; page < len(ids); page++ {
+				rows, err := transactions.Search(user.ID, query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, row := range rows {
+					seen = append(seen, row.ID)
+				}
+				if len(rows) < query.Limit {
+					break
+				}
+				cursor := models.NewTransactionCursor(rows[len(rows)-1], sortBy, desc)
+				query.Cursor = &cursor
+			}
+
+			if len(seen) != len(ids) {
+				t.Fatalf("%s desc=%v: paged %d rows, want %d", sortBy, desc, len(seen), len(ids))
+			}
+			for i := range ids {
+				want := ids[i]
+				if desc {
+					want = ids[len(ids)-1-i]
+				}
+				if seen[i] != want {
+					t.Errorf("%s desc=%v: row %d = %s, want %s", sortBy, desc, i, seen[i], want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+// Repositories groups the repositories that are bound to one database handle,
+// either the root connection or a transaction.
+type Repositories struct {
+	Users        UserRepository
+	Wallets      WalletRepository
+	Transactions TransactionRepository
+	Ledger       LedgerRepository
+}
+
+// UnitOfWork runs a function inside a single database transaction. The
+// repositories passed to fn share that transaction, so everything they write
+// is committed together or rolled back together when fn returns an error.
+//
+// Snapshot runs fn in a read-only REPEATABLE READ transaction, so every read
+// in fn sees the database as of the same moment.
+type UnitOfWork interface {
+	Do(fn func(repos Repositories) error) error
+	Snapshot(fn func(repos Repositories) error) error
+}
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Do(fn func(repos Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(newRepositories(tx))
+	})
+}
+
+func (u *unitOfWork) Snapshot(fn func(repos Repositories) error) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		return fn(newRepositories(tx))
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+func newRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:        NewUserRepository(db),
+		Wallets:      NewWalletRepository(db),
+		Transactions: NewTransactionRepository(db),
+		Ledger:       NewLedgerRepository(db),
+	}
+}
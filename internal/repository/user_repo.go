@@ -3,6 +3,7 @@ package repository
 import (
 	"crypto-wallet-service/internal/models"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -13,6 +14,9 @@ type UserRepository interface {
 	FindByEmail(email string) (*models.User, error)
 	FindByID(id uuid.UUID) (*models.User, error)
 	Update(user *models.User) error
+	UpdateTOTPLastStep(id uuid.UUID, step int64) (bool, error)
+	UpdatePassword(id uuid.UUID, passwordHash string) error
+	MarkEmailVerified(id uuid.UUID, at time.Time) error
 }
 
 type userRepository struct {
@@ -54,3 +58,25 @@ func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
 func (r *userRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
+
+// UpdateTOTPLastStep records the time step of an accepted TOTP code. It
+// returns false when that step or a later one was already used, so the same
+// code cannot be accepted twice, even by concurrent requests.
+func (r *userRepository) UpdateTOTPLastStep(id uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepository) UpdatePassword(id uuid.UUID, passwordHash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("password", passwordHash).Error
+}
+
+// MarkEmailVerified sets the verification time unless the email was
+// already verified
+func (r *userRepository) MarkEmailVerified(id uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", at).Error
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"crypto-wallet-service/internal/models"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUserTokenInvalid = errors.New("invalid or expired token")
+
+type UserTokenRepository interface {
+	Create(token *models.UserToken) error
+	Consume(purpose, tokenHash string, now time.Time) (*models.UserToken, error)
+	InvalidateForUser(userID uuid.UUID, purpose string) error
+	CountSince(userID uuid.UUID, purpose string, since time.Time) (int64, error)
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(token *models.UserToken) error {
+	return r.db.Create(token).Error
+}
+
+// Consume marks an unused, unexpired token as used and returns it. Marking
+// and checking happen in one statement, so a token works exactly once even
+// under concurrent requests.
+func (r *userTokenRepository) Consume(purpose, tokenHash string, now time.Time) (*models.UserToken, error) {
+	var tokens []models.UserToken
+	result := r.db.Model(&tokens).
+		Clauses(clause.Returning{}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || len(tokens) == 0 {
+		return nil, ErrUserTokenInvalid
+	}
+	return &tokens[0], nil
+}
+
+// InvalidateForUser marks the user's unused tokens of a purpose as used, so
+// only the most recently mailed token works
+func (r *userTokenRepository) InvalidateForUser(userID uuid.UUID, purpose string) error {
+	return r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now().UTC()).Error
+}
+
+// CountSince counts the tokens of a purpose issued to the user since the
+// given time, used to limit how many emails a user can trigger
+func (r *userTokenRepository) CountSince(userID uuid.UUID, purpose string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND created_at >= ?", userID, purpose, since).
+		Count(&count).Error
+	return count, err
+}
@@ -5,15 +5,26 @@ import (
 	"errors"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
 type WalletRepository interface {
 	Create(wallet *models.Wallet) error
+	CreateIfNotExists(userID uuid.UUID, currency string) error
+	FindByID(id uuid.UUID) (*models.Wallet, error)
 	FindByUserIDAndCurrency(userID uuid.UUID, currency string) (*models.Wallet, error)
+	FindByUserIDAndCurrencyForUpdate(userID uuid.UUID, currency string) (*models.Wallet, error)
+	FindByIDForUpdate(id uuid.UUID) (*models.Wallet, error)
 	FindAllByUserID(userID uuid.UUID) ([]models.Wallet, error)
+	FindAll() ([]models.Wallet, error)
 	Update(wallet *models.Wallet) error
-	UpdateBalance(walletID uuid.UUID, newBalance float64) error
+	UpdateBalance(walletID uuid.UUID, newBalance decimal.Decimal) error
+	AddBalance(walletID uuid.UUID, delta decimal.Decimal) error
+	SetFrozen(walletID uuid.UUID, frozen bool) error
 }
 
 type walletRepository struct {
@@ -28,6 +39,29 @@ func (r *walletRepository) Create(wallet *models.Wallet) error {
 	return r.db.Create(wallet).Error
 }
 
+// CreateIfNotExists inserts an empty wallet, doing nothing when the user already
+// has a wallet for the currency
+func (r *walletRepository) CreateIfNotExists(userID uuid.UUID, currency string) error {
+	wallet := &models.Wallet{
+		UserID:   userID,
+		Currency: currency,
+		Balance:  decimal.Zero,
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(wallet).Error
+}
+
+func (r *walletRepository) FindByID(id uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.Where("id = ?", id).First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
 func (r *walletRepository) FindByUserIDAndCurrency(userID uuid.UUID, currency string) (*models.Wallet, error) {
 	var wallet models.Wallet
 	err := r.db.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error
@@ -49,12 +83,76 @@ func (r *walletRepository) FindAllByUserID(userID uuid.UUID) ([]models.Wallet, e
 	return wallets, nil
 }
 
+func (r *walletRepository) FindAll() ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	err := r.db.Order("user_id, currency").Find(&wallets).Error
+	if err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
 func (r *walletRepository) Update(wallet *models.Wallet) error {
 	return r.db.Save(wallet).Error
 }
 
-func (r *walletRepository) UpdateBalance(walletID uuid.UUID, newBalance float64) error {
+func (r *walletRepository) UpdateBalance(walletID uuid.UUID, newBalance decimal.Decimal) error {
 	return r.db.Model(&models.Wallet{}).
 		Where("id = ?", walletID).
 		Update("balance", newBalance).Error
 }
+
+// FindByUserIDAndCurrencyForUpdate loads the wallet with SELECT ... FOR UPDATE.
+// It must be called on a transaction-scoped repository; the row stays locked
+// until that transaction ends.
+func (r *walletRepository) FindByUserIDAndCurrencyForUpdate(userID uuid.UUID, currency string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency = ?", userID, currency).
+		First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// FindByIDForUpdate is FindByID with SELECT ... FOR UPDATE; like
+// FindByUserIDAndCurrencyForUpdate it needs a transaction-scoped repository
+func (r *walletRepository) FindByIDForUpdate(id uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// AddBalance atomically adds delta (which may be negative) to the balance.
+// The update is refused with ErrInsufficientBalance if it would leave the
+// balance below zero.
+func (r *walletRepository) AddBalance(walletID uuid.UUID, delta decimal.Decimal) error {
+	result := r.db.Model(&models.Wallet{}).
+		Where("id = ? AND balance + ? >= 0", walletID, delta).
+		Update("balance", gorm.Expr("balance + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
+func (r *walletRepository) SetFrozen(walletID uuid.UUID, frozen bool) error {
+	return r.db.Model(&models.Wallet{}).
+		Where("id = ?", walletID).
+		Update("frozen", frozen).Error
+}
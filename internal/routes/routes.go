@@ -3,6 +3,8 @@ package routes
 import (
 	"crypto-wallet-service/internal/handlers"
 	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,42 +15,97 @@ func SetupRoutes(
 	authHandler *handlers.AuthHandler,
 	walletHandler *handlers.WalletHandler,
 	transactionHandler *handlers.TransactionHandler,
+	adminHandler *handlers.AdminHandler,
+	assetHandler *handlers.AssetHandler,
+	reportHandler *handlers.ReportHandler,
+	healthHandler *handlers.HealthHandler,
+	priceHandler *handlers.PriceHandler,
+	mfaHandler *handlers.MFAHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	userRepo repository.UserRepository,
 ) {
-	
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+
+	router.GET("/health", healthHandler.Health)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
 
 	
 	api := router.Group("/api")
 	{
+		api.GET("/assets", assetHandler.ListAssets)
+		api.GET("/prices/:symbol/history", priceHandler.GetHistory)
+
 		
 		auth := api.Group("/auth")
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/login/mfa", authHandler.LoginMFA)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/verify", authHandler.VerifyEmail)
+			auth.POST("/verify/resend", middleware.AuthMiddleware(), authHandler.ResendVerification)
+			auth.POST("/forgot-password", authHandler.ForgotPassword)
+			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.POST("/logout", middleware.AuthMiddleware(), authHandler.Logout)
+
+			mfa := auth.Group("/2fa")
+			mfa.Use(middleware.AuthMiddleware())
+			{
+				mfa.POST("/setup", mfaHandler.Setup)
+				mfa.POST("/confirm", mfaHandler.Confirm)
+				mfa.POST("/disable", mfaHandler.Disable)
+				mfa.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+			}
 		}
 
 		
+		// Routes that declare a scope accept a login session or an API key
+		// with that scope
+		wallet := api.Group("/wallet")
+		{
+			wallet.GET("", middleware.AuthMiddleware(models.ScopeReadWallet), walletHandler.GetWallet)
+			wallet.GET("/history", middleware.AuthMiddleware(models.ScopeReadWallet), walletHandler.GetHistory)
+			wallet.GET("/pnl", middleware.AuthMiddleware(models.ScopeReadWallet), walletHandler.GetPnL)
+			wallet.POST("/deposit", middleware.AuthMiddleware(models.ScopeTrade), middleware.IdempotencyMiddleware(), walletHandler.Deposit)
+			wallet.POST("/withdraw", middleware.AuthMiddleware(models.ScopeWithdraw), middleware.IdempotencyMiddleware(), walletHandler.Withdraw)
+			wallet.POST("/transfer", middleware.AuthMiddleware(models.ScopeWithdraw), middleware.IdempotencyMiddleware(), walletHandler.Transfer)
+		}
+
+		api.GET("/transactions", middleware.AuthMiddleware(models.ScopeReadTransactions), transactionHandler.GetTransactions)
+		api.GET("/transactions/export", middleware.AuthMiddleware(models.ScopeReadTransactions), transactionHandler.ExportTransactions)
+		api.POST("/transactions/import", middleware.AuthMiddleware(models.ScopeTrade), transactionHandler.ImportTransactions)
+		api.GET("/transactions/:id", middleware.AuthMiddleware(models.ScopeReadTransactions), transactionHandler.GetTransaction)
+
+		api.GET("/reports/tax", middleware.AuthMiddleware(models.ScopeReadTransactions), reportHandler.GetTaxReport)
+
+		
+		// Login sessions only
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware())
 		{
-		
 			user := protected.Group("/user")
 			{
 				user.GET("/me", authHandler.GetMe)
 			}
 
-		
-			wallet := protected.Group("/wallet")
+			apiKeys := protected.Group("/api-keys")
 			{
-				wallet.GET("", walletHandler.GetWallet)
-				wallet.POST("/deposit", walletHandler.Deposit)
-				wallet.POST("/withdraw", walletHandler.Withdraw)
+				apiKeys.GET("", apiKeyHandler.ListAPIKeys)
+				apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+				apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
 			}
 
 			
-			protected.GET("/transactions", transactionHandler.GetTransactions)
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware(userRepo))
+			{
+				admin.GET("/reconciliation", adminHandler.GetReconciliation)
+				admin.POST("/wallets/:id/freeze", adminHandler.FreezeWallet)
+				admin.POST("/wallets/:id/unfreeze", adminHandler.UnfreezeWallet)
+
+				admin.GET("/assets", assetHandler.ListAllAssets)
+				admin.POST("/assets", assetHandler.CreateAsset)
+				admin.PUT("/assets/:symbol", assetHandler.UpdateAsset)
+			}
 		}
 	}
 }
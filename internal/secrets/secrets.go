@@ -0,0 +1,85 @@
+// Package secrets encrypts small secrets stored in the database, such as
+// TOTP secrets and API key signing secrets, with AES-256-GCM. Each feature
+// loads its own Box from its own key.
+package secrets
+
+import (
+	"crypto-wallet-service/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// KeySize is the length of an AES-256 key in bytes
+const KeySize = 32
+
+// Box encrypts and decrypts secrets with one key. The additional data given
+// to Seal must be given to Open again, which binds a secret to its owner.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box using a raw 32 byte key
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Load returns a Box for the base64 key configured in the setting named
+// name. Without one a key is derived from JWT_SECRET and purpose, which is
+// fine for development only, so release mode refuses to start.
+func Load(name, encoded, purpose string) (*Box, error) {
+	if encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("%s must be %d bytes encoded as base64", name, KeySize)
+		}
+		return New(key)
+	}
+
+	if config.AppConfig.Server.Mode == "release" {
+		return nil, fmt.Errorf("%s must be set when GIN_MODE=release", name)
+	}
+	log.Printf("%s is not set, deriving it from JWT_SECRET", name)
+	sum := sha256.Sum256([]byte(purpose + ":" + config.AppConfig.JWT.Secret))
+	return New(sum[:])
+}
+
+// Seal encrypts secret; the result is base64 of the nonce followed by the
+// ciphertext
+func (b *Box) Seal(additionalData []byte, secret string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), additionalData)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal
+func (b *Box) Open(additionalData []byte, encrypted string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("encrypted secret is too short")
+	}
+	return b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData)
+}
@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"crypto-wallet-service/config"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func withConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	previous := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+func TestBoxBindsSecretToAdditionalData(t *testing.T) {
+	box, err := New(make([]byte, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal([]byte("owner-a"), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "secret") {
+		t.Fatalf("sealed value %q contains the secret", sealed)
+	}
+	if opened, err := box.Open([]byte("owner-a"), sealed); err != nil || string(opened) != "secret" {
+		t.Errorf("Open = %q, %v, want the secret", opened, err)
+	}
+	if _, err := box.Open([]byte("owner-b"), sealed); err == nil {
+		t.Error("the secret opened with other additional data")
+	}
+	if _, err := box.Open([]byte("owner-a"), "c2hvcnQ="); err == nil {
+		t.Error("a truncated value opened")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, KeySize))
+
+	t.Run("configured key", func(t *testing.T) {
+		withConfig(t, &config.Config{Server: config.ServerConfig{Mode: "release"}})
+		if _, err := Load("TEST_KEY", key, "test"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("key of the wrong size", func(t *testing.T) {
+		withConfig(t, &config.Config{})
+		short := base64.StdEncoding.EncodeToString(make([]byte, 16))
+		if _, err := Load("TEST_KEY", short, "test"); err == nil {
+			t.Fatal("a 16 byte key was accepted")
+		}
+	})
+
+	t.Run("derived in development", func(t *testing.T) {
+		withConfig(t, &config.Config{JWT: config.JWTConfig{Secret: "jwt-secret"}})
+		first, err := Load("TEST_KEY", "", "first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := Load("TEST_KEY", "", "second")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed, err := first.Seal(nil, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := second.Open(nil, sealed); err == nil {
+			t.Error("keys derived for different purposes are the same")
+		}
+	})
+
+	t.Run("refused in release mode", func(t *testing.T) {
+		withConfig(t, &config.Config{Server: config.ServerConfig{Mode: "release"}, JWT: config.JWTConfig{Secret: "jwt-secret"}})
+		if _, err := Load("TEST_KEY", "", "test"); err == nil || !strings.Contains(err.Error(), "TEST_KEY") {
+			t.Fatalf("err = %v, want TEST_KEY to be required", err)
+		}
+	})
+}
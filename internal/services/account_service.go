@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidAccountToken  = repository.ErrUserTokenInvalid
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrTooManyAccountEmails = errors.New("too many emails requested, try again later")
+)
+
+// mailTimeout bounds sending one email in the background
+const mailTimeout = 30 * time.Second
+
+// AccountService handles email verification and password reset. Both mail
+// a single-use token that expires; only its hash is stored.
+type AccountService struct {
+	userRepo      repository.UserRepository
+	userTokenRepo repository.UserTokenRepository
+	apiKeyRepo    repository.APIKeyRepository
+	tokenService  *TokenService
+	mailer        Mailer
+
+	appBaseURL       string
+	maxEmailsPerHour int
+}
+
+func NewAccountService(
+	userRepo repository.UserRepository,
+	userTokenRepo repository.UserTokenRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	tokenService *TokenService,
+	mailer Mailer,
+) *AccountService {
+	return &AccountService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		apiKeyRepo:    apiKeyRepo,
+		tokenService:  tokenService,
+		mailer:        mailer,
+
+		appBaseURL:       strings.TrimRight(config.AppConfig.Account.AppBaseURL, "/"),
+		maxEmailsPerHour: config.AppConfig.Account.MaxEmailsPerHour,
+	}
+}
+
+// SendVerificationEmail mails a new verification link. Earlier links stop
+// working.
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := s.issue(user.ID, models.UserTokenPurposeEmailVerification, config.GetVerificationTokenTTL())
+	if err != nil {
+		return err
+	}
+
+	s.sendInBackground(Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening this link:\n\n%s\n\nOr send this token to POST /api/auth/verify:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, s.link("/verify-email", token), token, config.GetVerificationTokenTTL()),
+		Secrets: []string{token},
+	})
+	return nil
+}
+
+// ResendVerificationEmail is SendVerificationEmail for a signed in user
+func (s *AccountService) ResendVerificationEmail(userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail consumes a verification token and marks the email as verified
+func (s *AccountService) VerifyEmail(rawToken string) error {
+	token, err := s.userTokenRepo.Consume(models.UserTokenPurposeEmailVerification, hashToken(rawToken), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(token.UserID, time.Now().UTC())
+}
+
+// ForgotPassword mails a password reset link. Unknown emails are ignored
+// without an error so the endpoint does not reveal who has an account.
+func (s *AccountService) ForgotPassword(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := s.issue(user.ID, models.UserTokenPurposePasswordReset, config.GetPasswordResetTokenTTL())
+	if err != nil {
+		if errors.Is(err, ErrTooManyAccountEmails) {
+			log.Printf("Password reset email limit reached for user %s", user.ID)
+			return nil
+		}
+		return err
+	}
+
+	s.sendInBackground(Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. If that was you, open this link:\n\n%s\n\nOr send this token to POST /api/auth/reset-password:\n\n%s\n\nThe link expires in %s. If you did not ask for this, ignore this email.\n",
+			user.Name, s.link("/reset-password", token), token, config.GetPasswordResetTokenTTL()),
+		Secrets: []string{token},
+	})
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token, logs the user out of
+// every session and revokes all of their API keys, since whoever took over
+// the account may have created some. The reset also proves the user owns
+// the email.
+func (s *AccountService) ResetPassword(ctx context.Context, rawToken, newPassword string) error {
+	now := time.Now().UTC()
+	token, err := s.userTokenRepo.Consume(models.UserTokenPurposePasswordReset, hashToken(rawToken), now)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(token.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	if err := s.userTokenRepo.InvalidateForUser(token.UserID, models.UserTokenPurposePasswordReset); err != nil {
+		log.Printf("Failed to invalidate reset tokens of user %s: %v", token.UserID, err)
+	}
+	if err := s.userRepo.MarkEmailVerified(token.UserID, now); err != nil {
+		log.Printf("Failed to mark email of user %s as verified: %v", token.UserID, err)
+	}
+
+	revoked, err := s.apiKeyRepo.RevokeAllForUser(token.UserID)
+	if err != nil {
+		return err
+	}
+	if revoked > 0 {
+		log.Printf("Revoked %d API keys of user %s after a password reset", revoked, token.UserID)
+	}
+
+	return s.tokenService.RevokeAllSessions(ctx, token.UserID)
+}
+
+// issue creates a token after checking the per-hour email limit and
+// invalidates the user's earlier tokens of the same purpose
+func (s *AccountService) issue(userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now().UTC()
+
+	if s.maxEmailsPerHour > 0 {
+		count, err := s.userTokenRepo.CountSince(userID, purpose, now.Add(-time.Hour))
+		if err != nil {
+			return "", err
+		}
+		if count >= int64(s.maxEmailsPerHour) {
+			return "", ErrTooManyAccountEmails
+		}
+	}
+
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.userTokenRepo.InvalidateForUser(userID, purpose); err != nil {
+		return "", err
+	}
+	if err := s.userTokenRepo.Create(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.appBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendInBackground keeps slow mail servers out of the request, which also
+// keeps response times from revealing whether an email is registered
+func (s *AccountService) sendInBackground(email Email) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := s.mailer.Send(ctx, email); err != nil {
+			log.Printf("Failed to send %q email to %s: %v", email.Subject, email.To, err)
+		}
+	}()
+}
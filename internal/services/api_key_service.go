@@ -0,0 +1,168 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPIKeyNotFound      = repository.ErrAPIKeyNotFound
+	ErrInvalidAPIKeyScope  = errors.New("invalid API key scope")
+	ErrInvalidAllowedIP    = errors.New("invalid IP address or CIDR in allowed_ips")
+	ErrInvalidAPIKeyExpiry = errors.New("expires_at must be in the future")
+	ErrTooManyAPIKeys      = fmt.Errorf("a user can have at most %d active API keys", maxAPIKeysPerUser)
+)
+
+const (
+	maxAPIKeysPerUser = 20
+
+	// apiKeyDisplayLength is how much of the key is kept as its prefix so
+	// users can tell their keys apart
+	apiKeyDisplayLength = 12
+)
+
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{apiKeyRepo: apiKeyRepo}
+}
+
+// Create issues a new API key together with its request signing secret.
+// Both are only part of this response; afterwards just the hash of the key
+// and the encrypted secret are stored.
+func (s *APIKeyService) Create(userID uuid.UUID, req models.CreateAPIKeyRequest) (*models.APIKeyCreatedResponse, error) {
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	allowedIPs, err := normalizeAllowedIPs(req.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidAPIKeyExpiry
+	}
+
+	active, err := s.apiKeyRepo.CountActive(userID)
+	if err != nil {
+		return nil, err
+	}
+	if active >= maxAPIKeysPerUser {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	raw := middleware.APIKeyPrefix + secret
+
+	signingSecret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.APIKey{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Name:       strings.TrimSpace(req.Name),
+		Prefix:     raw[:apiKeyDisplayLength],
+		KeyHash:    middleware.HashAPIKey(raw),
+		Scopes:     strings.Join(scopes, ","),
+		AllowedIPs: strings.Join(allowedIPs, ","),
+	}
+	if key.SigningSecret, err = middleware.EncryptSigningSecret(key.ID, signingSecret); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.apiKeyRepo.Create(key); err != nil {
+		return nil, err
+	}
+
+	return &models.APIKeyCreatedResponse{
+		APIKeyResponse: key.ToResponse(),
+		Key:            raw,
+		SigningSecret:  signingSecret,
+	}, nil
+}
+
+func (s *APIKeyService) List(userID uuid.UUID) ([]models.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.APIKeyResponse, len(keys))
+	for i := range keys {
+		responses[i] = keys[i].ToResponse()
+	}
+	return responses, nil
+}
+
+// Revoke disables the key immediately
+func (s *APIKeyService) Revoke(userID, keyID uuid.UUID) error {
+	return s.apiKeyRepo.Revoke(userID, keyID)
+}
+
+// normalizeScopes checks every scope and drops duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		valid := false
+		for _, known := range models.APIKeyScopes {
+			if scope == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: %q, use %s", ErrInvalidAPIKeyScope, scope, strings.Join(models.APIKeyScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+// normalizeAllowedIPs accepts IP addresses and CIDR ranges
+func normalizeAllowedIPs(entries []string) ([]string, error) {
+	var result []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidAllowedIP, entry)
+			}
+			result = append(result, network.String())
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAllowedIP, entry)
+		}
+		result = append(result, ip.String())
+	}
+	return result, nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// defaultAssets seeds an empty assets table with the currencies the service
+// originally shipped with
+var defaultAssets = []models.Asset{
+	{Symbol: "BTC", Name: "Bitcoin", ProviderID: "bitcoin", Decimals: 8},
+	{Symbol: "ETH", Name: "Ethereum", ProviderID: "ethereum", Decimals: 18},
+	{Symbol: "USDT", Name: "Tether", ProviderID: "tether", Decimals: 6},
+	{Symbol: "IDR", Name: "Indonesian Rupiah", Decimals: 2},
+}
+
+// AssetRegistry keeps the assets table in memory. It is loaded at startup,
+// reloaded after every admin change and periodically so that all instances
+// pick up changes made elsewhere.
+type AssetRegistry struct {
+	assetRepo repository.AssetRepository
+
+	mu     sync.RWMutex
+	assets map[string]models.Asset
+}
+
+func NewAssetRegistry(assetRepo repository.AssetRepository) *AssetRegistry {
+	return &AssetRegistry{
+		assetRepo: assetRepo,
+		assets:    map[string]models.Asset{},
+	}
+}
+
+// Load seeds the default assets when the table is empty and loads all assets
+func (r *AssetRegistry) Load() error {
+	count, err := r.assetRepo.Count()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		for _, asset := range defaultAssets {
+			asset.Enabled = true
+			asset.DepositEnabled = true
+			asset.WithdrawEnabled = true
+			if err := r.assetRepo.Create(&asset); err != nil {
+				return fmt.Errorf("failed to seed asset %s: %w", asset.Symbol, err)
+			}
+		}
+		log.Printf("Seeded %d default assets", len(defaultAssets))
+	}
+
+	return r.Reload()
+}
+
+// Reload replaces the in-memory registry with the current table contents
+func (r *AssetRegistry) Reload() error {
+	assets, err := r.assetRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	bySymbol := make(map[string]models.Asset, len(assets))
+	for _, asset := range assets {
+		bySymbol[asset.Symbol] = asset
+	}
+
+	r.mu.Lock()
+	r.assets = bySymbol
+	r.mu.Unlock()
+	return nil
+}
+
+// StartAutoReload reloads the registry every interval until ctx is done
+func (r *AssetRegistry) StartAutoReload(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Reload(); err != nil {
+					log.Printf("Failed to reload assets: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Get returns the asset for a symbol, whether or not it is enabled
+func (r *AssetRegistry) Get(symbol string) (models.Asset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	asset, ok := r.assets[strings.ToUpper(symbol)]
+	return asset, ok
+}
+
+// List returns every asset ordered by symbol
+func (r *AssetRegistry) List() []models.Asset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	assets := make([]models.Asset, 0, len(r.assets))
+	for _, asset := range r.assets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Symbol < assets[j].Symbol
+	})
+	return assets
+}
+
+// EnabledSymbols returns the symbols of all enabled assets
+func (r *AssetRegistry) EnabledSymbols() []string {
+	var symbols []string
+	for _, asset := range r.List() {
+		if asset.Enabled {
+			symbols = append(symbols, asset.Symbol)
+		}
+	}
+	return symbols
+}
+
+// PricedSymbols returns the enabled assets that need a market price, i.e.
+// everything except the quote currency
+func (r *AssetRegistry) PricedSymbols() []string {
+	var symbols []string
+	for _, symbol := range r.EnabledSymbols() {
+		if symbol != quoteCurrency {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// ProviderID returns the CoinGecko coin id for a symbol
+func (r *AssetRegistry) ProviderID(symbol string) (string, bool) {
+	asset, ok := r.Get(symbol)
+	if !ok || asset.ProviderID == "" {
+		return "", false
+	}
+	return asset.ProviderID, true
+}
+
+// ValidateOperation checks that the currency is listed, the operation is
+// enabled for it and the amount respects its precision and limits
+func (r *AssetRegistry) ValidateOperation(currency string, op models.AssetOperation, amount decimal.Decimal) (models.Asset, error) {
+	asset, ok := r.Get(currency)
+	if !ok || !asset.Enabled || asset.Symbol != currency {
+		return models.Asset{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+
+	if !asset.Allows(op) {
+		return models.Asset{}, fmt.Errorf("%s is currently disabled for %s", op, currency)
+	}
+
+	if err := asset.ValidateAmount(amount); err != nil {
+		return models.Asset{}, err
+	}
+
+	return asset, nil
+}
+
+// Create adds a new asset and reloads the registry
+func (r *AssetRegistry) Create(req models.AssetRequest) (*models.Asset, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if _, exists := r.Get(symbol); exists {
+		return nil, fmt.Errorf("asset %s already exists", symbol)
+	}
+
+	asset := &models.Asset{
+		Symbol:          symbol,
+		Enabled:         true,
+		DepositEnabled:  true,
+		WithdrawEnabled: true,
+	}
+	applyAssetRequest(asset, req)
+
+	if err := asset.Validate(); err != nil {
+		return nil, err
+	}
+	if err := r.assetRepo.Create(asset); err != nil {
+		return nil, err
+	}
+
+	return asset, r.Reload()
+}
+
+// Update changes an existing asset and reloads the registry
+func (r *AssetRegistry) Update(symbol string, req models.AssetRequest) (*models.Asset, error) {
+	asset, err := r.assetRepo.FindBySymbol(strings.ToUpper(symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	// Lowering precision would make existing balances unrepresentable
+	if req.Decimals < asset.Decimals {
+		return nil, errors.New("decimals cannot be decreased for an existing asset")
+	}
+
+	applyAssetRequest(asset, req)
+
+	if err := asset.Validate(); err != nil {
+		return nil, err
+	}
+	if err := r.assetRepo.Update(asset); err != nil {
+		return nil, err
+	}
+
+	return asset, r.Reload()
+}
+
+func applyAssetRequest(asset *models.Asset, req models.AssetRequest) {
+	asset.Name = req.Name
+	asset.ProviderID = req.ProviderID
+	asset.Decimals = req.Decimals
+	asset.MinAmount = req.MinAmount
+	asset.MaxAmount = req.MaxAmount
+	if req.Enabled != nil {
+		asset.Enabled = *req.Enabled
+	}
+	if req.DepositEnabled != nil {
+		asset.DepositEnabled = *req.DepositEnabled
+	}
+	if req.WithdrawEnabled != nil {
+		asset.WithdrawEnabled = *req.WithdrawEnabled
+	}
+}
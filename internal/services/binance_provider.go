@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BinanceProvider reads prices from a Binance-style /api/v3/ticker/price
+// endpoint, where every symbol is quoted as a trading pair such as BTCUSDT.
+// Any exchange exposing the same API shape can be used by changing the base URL.
+// Only assets with a provider id in the registry are priced, and only in the
+// vs currencies mapped to a quote asset, e.g. usd to USDT.
+type BinanceProvider struct {
+	apiURL     string
+	quotes     map[string]string
+	ids        ProviderIDLookup
+	httpClient *http.Client
+}
+
+type binanceTicker struct {
+	Symbol string          `json:"symbol"`
+	Price  decimal.Decimal `json:"price"`
+}
+
+// NewBinanceProvider takes the quote assets as vs:QUOTE pairs, e.g. usd:USDT
+func NewBinanceProvider(apiURL string, quotes []string, ids ProviderIDLookup) (*BinanceProvider, error) {
+	quoteByVs := make(map[string]string, len(quotes))
+	for _, pair := range quotes {
+		vs, quote, ok := strings.Cut(pair, ":")
+		vs, quote = strings.TrimSpace(vs), strings.TrimSpace(quote)
+		if !ok || vs == "" || quote == "" {
+			return nil, fmt.Errorf("invalid Binance quote %q, want vs:QUOTE", pair)
+		}
+		quoteByVs[strings.ToLower(vs)] = strings.ToUpper(quote)
+	}
+
+	return &BinanceProvider{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		quotes: quoteByVs,
+		ids:    ids,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+func (p *BinanceProvider) Name() string {
+	return "binance"
+}
+
+func (p *BinanceProvider) GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	quote, ok := p.quotes[strings.ToLower(vs)]
+	if !ok {
+		return nil, fmt.Errorf("Binance has no quote asset for %s", vs)
+	}
+
+	symbolByPair := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		if _, ok := p.ids.ProviderID(symbol); !ok {
+			continue
+		}
+		base := strings.ToUpper(symbol)
+		if base == quote {
+			continue
+		}
+		symbolByPair[base+quote] = symbol
+	}
+	if len(symbolByPair) == 0 {
+		return map[string]decimal.Decimal{}, nil
+	}
+
+	// Asking for a list of symbols fails the whole request when one pair is
+	// not listed, and the full ticker list costs the same request weight
+	endpoint := fmt.Sprintf("%s/api/v3/ticker/price", p.apiURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch prices from Binance: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Binance answers 418 once an IP keeps ignoring 429s
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		return nil, newRateLimitError(p.Name(), resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Binance API returned status code: %d", resp.StatusCode)
+	}
+
+	var tickers []binanceTicker
+	if err := json.NewDecoder(resp.Body).Decode(&tickers); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	prices := make(map[string]decimal.Decimal, len(symbolByPair))
+	for _, ticker := range tickers {
+		if symbol, ok := symbolByPair[ticker.Symbol]; ok {
+			prices[symbol] = ticker.Price
+		}
+	}
+
+	return prices, nil
+}
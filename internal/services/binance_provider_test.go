@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeProviderIDs lists the symbols that have a price feed
+type fakeProviderIDs map[string]string
+
+func (f fakeProviderIDs) ProviderID(symbol string) (string, bool) {
+	id, ok := f[symbol]
+	return id, ok
+}
+
+func newTestBinanceProvider(t *testing.T, requests *int) *BinanceProvider {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != "/api/v3/ticker/price" || r.URL.RawQuery != "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[
+			{"symbol": "BTCUSDT", "price": "65000.12"},
+			{"symbol": "ETHUSDT", "price": "3100.5"},
+			{"symbol": "ETHBTC", "price": "0.0477"}
+		]`))
+	}))
+	t.Cleanup(server.Close)
+
+	ids := fakeProviderIDs{"BTC": "bitcoin", "ETH": "ethereum", "USDT": "tether", "NEWCOIN": "new-coin"}
+	provider, err := NewBinanceProvider(server.URL, []string{"usd:USDT", "btc:BTC"}, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestBinanceSkipsSymbolsWithoutPair(t *testing.T) {
+	requests := 0
+	provider := newTestBinanceProvider(t, &requests)
+
+	// IDR has no provider id, NEWCOIN is not listed on the exchange and USDT
+	// is the quote asset itself; none of them fails the others
+	prices, err := provider.GetPrices(context.Background(), []string{"BTC", "ETH", "IDR", "NEWCOIN", "USDT"}, "usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPrices(t, prices, map[string]string{"BTC": "65000.12", "ETH": "3100.5"})
+
+	prices, err = provider.GetPrices(context.Background(), []string{"BTC", "ETH"}, "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPrices(t, prices, map[string]string{"ETH": "0.0477"})
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+}
+
+func TestBinanceRefusesUnmappedVsCurrency(t *testing.T) {
+	requests := 0
+	provider := newTestBinanceProvider(t, &requests)
+
+	if _, err := provider.GetPrices(context.Background(), []string{"BTC"}, "idr"); err == nil {
+		t.Error("pricing in idr without a quote asset succeeded")
+	}
+	if requests != 0 {
+		t.Errorf("made %d requests for an unsupported vs currency, want 0", requests)
+	}
+}
+
+func TestNewBinanceProviderRejectsInvalidQuotes(t *testing.T) {
+	for _, quotes := range [][]string{{"usd"}, {"usd:"}, {":USDT"}} {
+		if _, err := NewBinanceProvider("http://localhost", quotes, fakeProviderIDs{}); err == nil {
+			t.Errorf("quotes %v accepted", quotes)
+		}
+	}
+}
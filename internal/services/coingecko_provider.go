@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CoinGeckoProvider reads prices from the CoinGecko /simple/price endpoint.
+// Symbols are mapped to CoinGecko coin ids through the asset registry.
+type CoinGeckoProvider struct {
+	apiURL     string
+	ids        ProviderIDLookup
+	httpClient *http.Client
+}
+
+func NewCoinGeckoProvider(apiURL string, ids ProviderIDLookup) *CoinGeckoProvider {
+	return &CoinGeckoProvider{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		ids:    ids,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (p *CoinGeckoProvider) Name() string {
+	return "coingecko"
+}
+
+func (p *CoinGeckoProvider) GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	vs = strings.ToLower(vs)
+
+	ids := make([]string, 0, len(symbols))
+	symbolByID := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		id, ok := p.ids.ProviderID(symbol)
+		if !ok {
+			continue
+		}
+		ids = append(ids, id)
+		symbolByID[id] = symbol
+	}
+	if len(ids) == 0 {
+		return map[string]decimal.Decimal{}, nil
+	}
+
+	query := url.Values{}
+	query.Set("ids", strings.Join(ids, ","))
+	query.Set("vs_currencies", vs)
+	endpoint := fmt.Sprintf("%s/simple/price?%s", p.apiURL, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch prices from CoinGecko: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, newRateLimitError(p.Name(), resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CoinGecko API returned status code: %d", resp.StatusCode)
+	}
+
+	var data map[string]map[string]decimal.Decimal
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	prices := make(map[string]decimal.Decimal, len(data))
+	for id, quotes := range data {
+		symbol, ok := symbolByID[id]
+		if !ok {
+			continue
+		}
+		if price, ok := quotes[vs]; ok {
+			prices[symbol] = price
+		}
+	}
+
+	return prices, nil
+}
+
+// HistoricalPrice is one point of a provider's price chart
+type HistoricalPrice struct {
+	Time  time.Time
+	Price decimal.Decimal
+}
+
+// GetDailyHistory reads daily prices for the last days from the CoinGecko
+// /coins/{id}/market_chart endpoint, oldest first
+func (p *CoinGeckoProvider) GetDailyHistory(ctx context.Context, symbol, vs string, days int) ([]HistoricalPrice, error) {
+	id, ok := p.ids.ProviderID(symbol)
+	if !ok {
+		return nil, fmt.Errorf("no CoinGecko id for %s", symbol)
+	}
+
+	query := url.Values{}
+	query.Set("vs_currency", strings.ToLower(vs))
+	query.Set("days", strconv.Itoa(days))
+	query.Set("interval", "daily")
+	endpoint := fmt.Sprintf("%s/coins/%s/market_chart?%s", p.apiURL, url.PathEscape(id), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch market chart from CoinGecko: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, newRateLimitError(p.Name(), resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CoinGecko API returned status code: %d", resp.StatusCode)
+	}
+
+	// Every point is a [unix milliseconds, price] pair
+	var data struct {
+		Prices [][2]decimal.Decimal `json:"prices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	prices := make([]HistoricalPrice, 0, len(data.Prices))
+	for _, point := range data.Prices {
+		prices = append(prices, HistoricalPrice{
+			Time:  time.UnixMilli(point[0].IntPart()).UTC(),
+			Price: point[1],
+		})
+	}
+	return prices, nil
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrImportInvalid     = errors.New("import contains invalid rows")
+	ErrInvalidImportFile = errors.New("invalid import file")
+)
+
+// MaxImportRows bounds a single upload
+const MaxImportRows = 5000
+
+var defaultImportDateFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// importFormats are the built-in column mappings. Another exchange is
+// supported by adding its mapping here; callers may also send their own.
+var importFormats = map[string]models.ImportMapping{
+	"generic": {
+		Source:         "generic",
+		DateColumn:     "date",
+		TypeColumn:     "type",
+		CurrencyColumn: "currency",
+		AmountColumn:   "amount",
+		PriceColumn:    "price_idr",
+		IDColumn:       "id",
+		Types: map[string]models.TransactionType{
+			"deposit":  models.TransactionTypeDeposit,
+			"buy":      models.TransactionTypeDeposit,
+			"withdraw": models.TransactionTypeWithdraw,
+			"sell":     models.TransactionTypeWithdraw,
+		},
+	},
+}
+
+// ImportFormat returns a built-in column mapping by name
+func ImportFormat(name string) (models.ImportMapping, bool) {
+	mapping, ok := importFormats[strings.ToLower(name)]
+	return mapping, ok
+}
+
+// importOperations is the asset operation each imported type must be
+// enabled for, so an import cannot bring in what the API would refuse
+var importOperations = map[models.TransactionType]models.AssetOperation{
+	models.TransactionTypeDeposit:  models.AssetOperationDeposit,
+	models.TransactionTypeWithdraw: models.AssetOperationWithdraw,
+}
+
+// ImportService brings transaction history from other exchanges in. Every
+// imported row becomes a deposit or withdrawal with its original date and
+// price and status imported. Imported rows feed P&L and tax reports but are
+// history only: they are not posted to the ledger and never move a wallet
+// balance. A row is identified by source and exchange id (or a hash of its
+// contents when the file has no id column) so importing the same file twice
+// is harmless.
+type ImportService struct {
+	transactionRepo repository.TransactionRepository
+	assets          *AssetRegistry
+	uow             repository.UnitOfWork
+}
+
+func NewImportService(
+	transactionRepo repository.TransactionRepository,
+	assets *AssetRegistry,
+	uow repository.UnitOfWork,
+) *ImportService {
+	return &ImportService{
+		transactionRepo: transactionRepo,
+		assets:          assets,
+		uow:             uow,
+	}
+}
+
+// Import parses and validates the CSV. In a dry run nothing is written and
+// the result previews every row. Otherwise all new rows are written in one
+// database transaction; if any row is invalid nothing is written and
+// ErrImportInvalid is returned together with the result.
+func (s *ImportService) Import(userID uuid.UUID, file io.Reader, mapping models.ImportMapping, dryRun bool) (*models.ImportResult, error) {
+	if mapping.Source == "" || len(mapping.Types) == 0 {
+		return nil, fmt.Errorf("%w: mapping needs a source and at least one type", ErrInvalidImportFile)
+	}
+
+	rows, err := s.parse(file, mapping)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	if err := s.markDuplicates(userID, rows); err != nil {
+		return nil, err
+	}
+	pending := pendingImportRows(rows)
+
+	result := &models.ImportResult{
+		Source:    mapping.Source,
+		DryRun:    dryRun,
+		TotalRows: len(rows),
+		Rows:      rows,
+	}
+	for _, row := range rows {
+		switch {
+		case len(row.Errors) > 0:
+			result.ErrorRows++
+		case row.Duplicate:
+			result.Duplicates++
+		default:
+			result.ValidRows++
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if result.ErrorRows > 0 {
+		return result, ErrImportInvalid
+	}
+
+	err = s.uow.Do(func(repos repository.Repositories) error {
+		return s.write(repos, userID, pending)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Imported = len(pending)
+	return result, nil
+}
+
+func (s *ImportService) parse(file io.Reader, mapping models.ImportMapping) ([]models.ImportRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != "" {
+		delimiter, _ := utf8.DecodeRuneInString(mapping.Delimiter)
+		reader.Comma = delimiter
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	for _, required := range []string{mapping.DateColumn, mapping.TypeColumn, mapping.CurrencyColumn, mapping.AmountColumn} {
+		if _, ok := columns[strings.ToLower(required)]; !ok {
+			return nil, fmt.Errorf("CSV is missing column %q", required)
+		}
+	}
+
+	formats := mapping.DateFormats
+	if len(formats) == 0 {
+		formats = defaultImportDateFormats
+	}
+
+	var rows []models.ImportRow
+	occurrences := map[string]int{}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(rows) >= MaxImportRows {
+			return nil, fmt.Errorf("CSV has more than %d rows", MaxImportRows)
+		}
+
+		field := func(column string) string {
+			i, ok := columns[strings.ToLower(column)]
+			if column == "" || !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := s.parseRow(line, field, mapping, formats)
+		if row.ExternalRef == "" && len(row.Errors) == 0 {
+			// Identical rows without an id are told apart by how often the
+			// same contents appeared before them in the file
+			content := contentImportRef(row)
+			occurrences[content]++
+			row.ExternalRef = fmt.Sprintf("%s:sha256:%s:%d", mapping.Source, content, occurrences[content])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func (s *ImportService) parseRow(line int, field func(string) string, mapping models.ImportMapping, formats []string) models.ImportRow {
+	row := models.ImportRow{Line: line}
+	addError := func(format string, args ...interface{}) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+	}
+
+	date, err := parseImportDate(field(mapping.DateColumn), formats)
+	if err != nil {
+		addError("invalid date %q", field(mapping.DateColumn))
+	} else if date.After(time.Now()) {
+		addError("date is in the future")
+	}
+	row.Date = date
+
+	rawType := strings.ToLower(field(mapping.TypeColumn))
+	if txType, ok := mapping.Types[rawType]; ok && (txType == models.TransactionTypeDeposit || txType == models.TransactionTypeWithdraw) {
+		row.Type = txType
+	} else {
+		addError("unsupported type %q", rawType)
+	}
+
+	row.Currency = strings.ToUpper(field(mapping.CurrencyColumn))
+	_, listed := s.assets.Get(row.Currency)
+	if !listed {
+		addError("%s: %s", ErrUnsupportedCurrency, row.Currency)
+	}
+
+	amount, err := decimal.NewFromString(field(mapping.AmountColumn))
+	if err != nil {
+		addError("invalid amount %q", field(mapping.AmountColumn))
+	} else if op, ok := importOperations[row.Type]; ok && listed {
+		// The same checks the API applies: the operation being enabled,
+		// precision and the min/max limits
+		if _, err := s.assets.ValidateOperation(row.Currency, op, amount); err != nil {
+			addError("%s", err)
+		}
+	}
+	row.Amount = amount
+
+	if value := field(mapping.PriceColumn); value != "" {
+		price, err := decimal.NewFromString(value)
+		if err != nil || price.IsNegative() {
+			addError("invalid price %q", value)
+		}
+		row.PriceIDR = price
+	} else if row.Currency == quoteCurrency {
+		row.PriceIDR = decimal.NewFromInt(1)
+	}
+
+	if id := field(mapping.IDColumn); id != "" {
+		row.ExternalRef = mapping.Source + ":" + id
+	}
+
+	return row
+}
+
+// contentImportRef hashes the contents of a row that has no exchange id
+func contentImportRef(row models.ImportRow) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		row.Date.UTC().Format(time.RFC3339Nano), string(row.Type), row.Currency, row.Amount.String(),
+	}, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func parseImportDate(value string, formats []string) (time.Time, error) {
+	for _, layout := range formats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized date")
+}
+
+// markDuplicates flags rows that repeat an earlier row of the same file or a
+// transaction imported before
+func (s *ImportService) markDuplicates(userID uuid.UUID, rows []models.ImportRow) error {
+	var refs []string
+	for _, row := range rows {
+		if row.ExternalRef != "" {
+			refs = append(refs, row.ExternalRef)
+		}
+	}
+
+	existing, err := s.transactionRepo.FindExternalRefs(userID, refs)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(rows))
+	for _, ref := range existing {
+		seen[ref] = true
+	}
+	for i := range rows {
+		ref := rows[i].ExternalRef
+		if ref == "" {
+			continue
+		}
+		if seen[ref] {
+			rows[i].Duplicate = true
+		}
+		seen[ref] = true
+	}
+	return nil
+}
+
+// pendingImportRows returns the valid rows that were not imported before,
+// oldest first
+func pendingImportRows(rows []models.ImportRow) []*models.ImportRow {
+	var pending []*models.ImportRow
+	for i := range rows {
+		if len(rows[i].Errors) == 0 && !rows[i].Duplicate {
+			pending = append(pending, &rows[i])
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].Date.Before(pending[j].Date)
+	})
+	return pending
+}
+
+// write records the rows as imported history. They are deliberately not
+// posted to the ledger, so no wallet balance changes and nothing becomes
+// spendable.
+func (s *ImportService) write(repos repository.Repositories, userID uuid.UUID, rows []*models.ImportRow) error {
+	for _, row := range rows {
+		externalRef := row.ExternalRef
+
+		transaction := &models.Transaction{
+			UserID:      userID,
+			Type:        row.Type,
+			Direction:   models.TransactionDirectionCredit,
+			Currency:    row.Currency,
+			Amount:      row.Amount,
+			Status:      models.TransactionStatusImported,
+			PriceAt:     row.PriceIDR,
+			ExternalRef: &externalRef,
+			CreatedAt:   row.Date,
+		}
+		if row.Type == models.TransactionTypeWithdraw {
+			transaction.Direction = models.TransactionDirectionDebit
+		}
+
+		if err := repos.Transactions.Create(transaction); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func (r *fakeTransactionRepo) Create(transaction *models.Transaction) error {
+	r.transactions = append(r.transactions, *transaction)
+	return nil
+}
+
+func (r *fakeTransactionRepo) FindExternalRefs(userID uuid.UUID, refs []string) ([]string, error) {
+	var existing []string
+	for _, tx := range r.transactions {
+		for _, ref := range refs {
+			if tx.UserID == userID && tx.ExternalRef != nil && *tx.ExternalRef == ref {
+				existing = append(existing, ref)
+			}
+		}
+	}
+	return existing, nil
+}
+
+// fakeUnitOfWork runs fn against the given repositories without a
+// transaction; a repository left nil panics when used
+type fakeUnitOfWork struct {
+	repos repository.Repositories
+}
+
+func (u fakeUnitOfWork) Do(fn func(repos repository.Repositories) error) error {
+	return fn(u.repos)
+}
+
+func (u fakeUnitOfWork) Snapshot(fn func(repos repository.Repositories) error) error {
+	return u.Do(fn)
+}
+
+func newTestImportService(assets ...models.Asset) (*ImportService, *fakeTransactionRepo) {
+	registry := &AssetRegistry{assets: map[string]models.Asset{}}
+	for _, asset := range assets {
+		registry.assets[asset.Symbol] = asset
+	}
+	repo := &fakeTransactionRepo{}
+	return NewImportService(repo, registry, fakeUnitOfWork{repos: repository.Repositories{Transactions: repo}}), repo
+}
+
+func testAsset(symbol string, decimals int32) models.Asset {
+	return models.Asset{Symbol: symbol, Decimals: decimals, Enabled: true, DepositEnabled: true, WithdrawEnabled: true}
+}
+
+func importCSV(t *testing.T, service *ImportService, userID uuid.UUID, csv string, dryRun bool) *models.ImportResult {
+	t.Helper()
+	mapping, _ := ImportFormat("generic")
+	result, err := service.Import(userID, strings.NewReader(csv), mapping, dryRun)
+	if err != nil && err != ErrImportInvalid {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestImportRecordsHistoryWithoutMovingBalances(t *testing.T) {
+	service, repo := newTestImportService(testAsset("BTC", 8))
+	userID := uuid.New()
+
+	// The withdrawal exceeds anything the user holds here; imported history
+	// is not checked against wallet balances because it never touches them
+	result := importCSV(t, service, userID, "date,type,currency,amount,price_idr,id\n"+
+		"2025-01-10,buy,BTC,1,800000000,a1\n"+
+		"2025-02-10,sell,BTC,2,900000000,a2\n", false)
+
+	if result.Imported != 2 {
+		t.Fatalf("imported %d rows, want 2: %+v", result.Imported, result.Rows)
+	}
+	for _, tx := range repo.transactions {
+		if tx.Status != models.TransactionStatusImported || tx.AffectsBalance() {
+			t.Errorf("%s row has status %q, want imported", tx.Type, tx.Status)
+		}
+	}
+	if repo.transactions[1].Direction != models.TransactionDirectionDebit {
+		t.Errorf("sell direction = %s, want debit", repo.transactions[1].Direction)
+	}
+}
+
+func TestImportValidatesEveryRowLikeTheAPI(t *testing.T) {
+	noWithdraw := testAsset("ETH", 18)
+	noWithdraw.WithdrawEnabled = false
+	limited := testAsset("USDT", 6)
+	limited.MinAmount = dec("10")
+	limited.MaxAmount = dec("1000")
+
+	service, repo := newTestImportService(testAsset("BTC", 8), noWithdraw, limited)
+
+	result := importCSV(t, service, uuid.New(), "date,type,currency,amount,id\n"+
+		"2025-01-10,sell,ETH,1,r1\n"+
+		"2025-01-10,buy,USDT,5,r2\n"+
+		"2025-01-10,buy,USDT,5000,r3\n"+
+		"2025-01-10,buy,BTC,0.000000001,r4\n"+
+		"2025-01-10,buy,DOGE,1,r5\n"+
+		"2025-01-10,buy,ETH,1,r6\n", false)
+
+	wantErrors := []string{
+		"withdraw is currently disabled for ETH",
+		"minimum amount for USDT is 10",
+		"maximum amount for USDT is 1000",
+		"amount exceeds 8 decimal places allowed for BTC",
+		"unsupported currency: DOGE",
+	}
+	for i, want := range wantErrors {
+		if errors := result.Rows[i].Errors; len(errors) != 1 || errors[0] != want {
+			t.Errorf("line %d errors = %v, want [%s]", result.Rows[i].Line, errors, want)
+		}
+	}
+	if len(result.Rows[5].Errors) != 0 {
+		t.Errorf("line %d errors = %v, want none", result.Rows[5].Line, result.Rows[5].Errors)
+	}
+	if result.ErrorRows != 5 || len(repo.transactions) != 0 {
+		t.Errorf("error rows = %d, written = %d, want 5 and nothing written", result.ErrorRows, len(repo.transactions))
+	}
+}
+
+func TestImportKeepsIdenticalRowsWithoutID(t *testing.T) {
+	service, repo := newTestImportService(testAsset("BTC", 8))
+	userID := uuid.New()
+	file := "date,type,currency,amount\n" +
+		"2025-01-10,buy,BTC,0.5\n" +
+		"2025-01-10,buy,BTC,0.5\n" +
+		"2025-01-11,buy,BTC,0.5\n"
+
+	result := importCSV(t, service, userID, file, false)
+	if result.Imported != 3 || result.Duplicates != 0 {
+		t.Fatalf("imported %d, duplicates %d, want 3 and 0", result.Imported, result.Duplicates)
+	}
+	if first, second := result.Rows[0].ExternalRef, result.Rows[1].ExternalRef; first == second {
+		t.Errorf("identical rows share the reference %s", first)
+	}
+
+	// Importing the same file again is still recognised as a duplicate
+	again := importCSV(t, service, userID, file, false)
+	if again.Imported != 0 || again.Duplicates != 3 {
+		t.Errorf("re-import imported %d, duplicates %d, want 0 and 3", again.Imported, again.Duplicates)
+	}
+	if len(repo.transactions) != 3 {
+		t.Errorf("wrote %d transactions, want 3", len(repo.transactions))
+	}
+}
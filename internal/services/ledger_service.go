@@ -0,0 +1,208 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ledgerLeg is one side of a journal entry. When wallet is set the amount is
+// also applied to the cached Wallet.Balance of that (already locked) wallet.
+type ledgerLeg struct {
+	account models.LedgerAccount
+	amount  decimal.Decimal
+	wallet  *models.Wallet
+}
+
+func walletLeg(wallet *models.Wallet, amount decimal.Decimal) ledgerLeg {
+	return ledgerLeg{
+		account: models.UserWalletAccount(wallet.UserID, wallet.Currency),
+		amount:  amount,
+		wallet:  wallet,
+	}
+}
+
+func systemLeg(accountType models.LedgerAccountType, currency string, amount decimal.Decimal) ledgerLeg {
+	return ledgerLeg{
+		account: models.SystemAccount(accountType, currency),
+		amount:  amount,
+	}
+}
+
+// postEntry writes a balanced journal entry inside the current unit of work and
+// updates the wallet balance projection for every wallet leg
+func postEntry(repos repository.Repositories, entryType models.JournalEntryType, transactionID *uuid.UUID, description string, legs ...ledgerLeg) error {
+	entry := &models.JournalEntry{
+		Type:          entryType,
+		TransactionID: transactionID,
+		Description:   description,
+	}
+
+	for _, leg := range legs {
+		account, err := repos.Ledger.FindOrCreateAccount(leg.account)
+		if err != nil {
+			return err
+		}
+		entry.Postings = append(entry.Postings, models.Posting{
+			AccountID: account.ID,
+			Amount:    leg.amount,
+		})
+	}
+
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	if err := repos.Ledger.CreateEntry(entry); err != nil {
+		return err
+	}
+
+	for _, leg := range legs {
+		if leg.wallet == nil {
+			continue
+		}
+		if err := repos.Wallets.AddBalance(leg.wallet.ID, leg.amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LedgerService maintains the journal and keeps Wallet.Balance, which is only
+// a cached projection of the postings, consistent with it
+type LedgerService struct {
+	walletRepo repository.WalletRepository
+	ledgerRepo repository.LedgerRepository
+	uow        repository.UnitOfWork
+}
+
+func NewLedgerService(
+	walletRepo repository.WalletRepository,
+	ledgerRepo repository.LedgerRepository,
+	uow repository.UnitOfWork,
+) *LedgerService {
+	return &LedgerService{
+		walletRepo: walletRepo,
+		ledgerRepo: ledgerRepo,
+		uow:        uow,
+	}
+}
+
+// EnsureOpeningBalances posts an opening_balance entry for every wallet that
+// holds funds but has no postings yet, i.e. wallets created before the ledger
+// existed. It is safe to run on every startup, also on several instances at
+// once: every posting to a wallet account is made under the wallet's row
+// lock, so the check and the insert run under it too.
+func (s *LedgerService) EnsureOpeningBalances() error {
+	wallets, err := s.walletRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range wallets {
+		if candidate.Balance.IsZero() {
+			continue
+		}
+
+		candidate := candidate
+		err := s.uow.Do(func(repos repository.Repositories) error {
+			wallet, err := lockWallet(repos.Wallets, candidate.UserID, candidate.Currency, false)
+			if err != nil {
+				return err
+			}
+			if wallet.Balance.IsZero() {
+				return nil
+			}
+
+			account, err := repos.Ledger.FindOrCreateAccount(models.UserWalletAccount(wallet.UserID, wallet.Currency))
+			if err != nil {
+				return err
+			}
+			hasPostings, err := repos.Ledger.HasPostings(account.ID)
+			if err != nil || hasPostings {
+				return err
+			}
+
+			log.Printf("Ledger: opening balance %s %s for user %s", wallet.Balance, wallet.Currency, wallet.UserID)
+			return postEntry(repos, models.JournalEntryOpeningBalance, nil, "Opening balance",
+				ledgerLeg{account: *account, amount: wallet.Balance},
+				systemLeg(models.LedgerAccountClearing, wallet.Currency, wallet.Balance.Neg()),
+			)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RebuildBalances recomputes every Wallet.Balance from the postings and
+// returns the wallets whose cached balance had to be corrected. Each wallet
+// is summed under its row lock, so it is safe to run against a live server:
+// a movement committed meanwhile is part of the sum or waits for the lock.
+func (s *LedgerService) RebuildBalances() ([]models.BalanceCorrection, error) {
+	balances, err := s.ledgerRepo.UserWalletBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	type walletKey struct {
+		userID   uuid.UUID
+		currency string
+	}
+	seen := make(map[walletKey]bool, len(balances))
+	var keys []walletKey
+	add := func(key walletKey) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, b := range balances {
+		add(walletKey{b.UserID, b.Currency})
+	}
+
+	// Wallets without any ledger account must be empty
+	wallets, err := s.walletRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, wallet := range wallets {
+		add(walletKey{wallet.UserID, wallet.Currency})
+	}
+
+	corrections := []models.BalanceCorrection{}
+	for _, key := range keys {
+		key := key
+		err := s.uow.Do(func(repos repository.Repositories) error {
+			wallet, err := lockWallet(repos.Wallets, key.userID, key.currency, true)
+			if err != nil {
+				return err
+			}
+			balance, err := repos.Ledger.AccountBalance(models.UserWalletAccount(key.userID, key.currency).Code)
+			if err != nil {
+				return err
+			}
+			if wallet.Balance.Equal(balance) {
+				return nil
+			}
+
+			corrections = append(corrections, models.BalanceCorrection{
+				UserID:          key.userID,
+				Currency:        key.currency,
+				PreviousBalance: wallet.Balance,
+				LedgerBalance:   balance,
+			})
+			return repos.Wallets.UpdateBalance(wallet.ID, balance)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return corrections, nil
+}
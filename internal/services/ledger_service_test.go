@@ -0,0 +1,192 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+func newTestLedgerService(t *testing.T) (*LedgerService, *WalletService, repository.Repositories, *gorm.DB) {
+	t.Helper()
+
+	wallets, repos := newTestWalletService(t)
+	db := openTestDB(t)
+	return NewLedgerService(repos.Wallets, repos.Ledger, repository.NewUnitOfWork(db)), wallets, repos, db
+}
+
+// assertLedgerBalanced checks that the postings of every currency sum to zero
+func assertLedgerBalanced(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	var unbalanced []struct {
+		Currency string
+		Total    decimal.Decimal
+	}
+	err := db.Table("postings AS p").
+		Select("a.currency, SUM(p.amount) AS total").
+		Joins("JOIN ledger_accounts a ON a.id = p.account_id").
+		Group("a.currency").
+		Having("SUM(p.amount) <> 0").
+		Scan(&unbalanced).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range unbalanced {
+		t.Errorf("%s postings sum to %s, want 0", u.Currency, u.Total)
+	}
+}
+
+func ledgerBalance(t *testing.T, repos repository.Repositories, userID uuid.UUID, currency string) decimal.Decimal {
+	t.Helper()
+
+	balance, err := repos.Ledger.AccountBalance(models.UserWalletAccount(userID, currency).Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func walletBalance(t *testing.T, repos repository.Repositories, userID uuid.UUID, currency string) decimal.Decimal {
+	t.Helper()
+
+	wallet, err := repos.Wallets.FindByUserIDAndCurrency(userID, currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet == nil {
+		return decimal.Zero
+	}
+	return wallet.Balance
+}
+
+func TestLedgerOpeningBalancesArePostedOnce(t *testing.T) {
+	ledger, _, repos, db := newTestLedgerService(t)
+
+	// A wallet funded before the ledger existed has a balance but no postings
+	user := createTestUser(t, repos.Users)
+	opening := decimal.RequireFromString("1234.5")
+	if err := repos.Wallets.Create(&models.Wallet{UserID: user.ID, Currency: "IDR", Balance: opening}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ledger.EnsureOpeningBalances(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := ledgerBalance(t, repos, user.ID, "IDR"); !got.Equal(opening) {
+		t.Errorf("ledger balance = %s, want %s", got, opening)
+	}
+	var entries int64
+	err := db.Table("journal_entries AS e").
+		Joins("JOIN postings p ON p.entry_id = e.id").
+		Joins("JOIN ledger_accounts a ON a.id = p.account_id").
+		Where("a.code = ? AND e.type = ?", models.UserWalletAccount(user.ID, "IDR").Code, models.JournalEntryOpeningBalance).
+		Count(&entries).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries != 1 {
+		t.Errorf("opening balance entries = %d, want 1", entries)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestLedgerRebuildCorrectsDriftedBalance(t *testing.T) {
+	ledger, wallets, repos, db := newTestLedgerService(t)
+
+	user := createTestUser(t, repos.Users)
+	if err := wallets.Deposit(user.ID, "IDR", decimal.NewFromInt(600)); err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := repos.Wallets.FindByUserIDAndCurrency(user.ID, "IDR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Wallets.UpdateBalance(wallet.ID, decimal.NewFromInt(999)); err != nil {
+		t.Fatal(err)
+	}
+
+	corrections, err := ledger.RebuildBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found *models.BalanceCorrection
+	for i := range corrections {
+		if corrections[i].UserID == user.ID {
+			found = &corrections[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("no correction reported for the drifted wallet: %+v", corrections)
+	}
+	if !found.PreviousBalance.Equal(decimal.NewFromInt(999)) || !found.LedgerBalance.Equal(decimal.NewFromInt(600)) {
+		t.Errorf("correction = %+v, want 999 -> 600", *found)
+	}
+	if got := walletBalance(t, repos, user.ID, "IDR"); !got.Equal(decimal.NewFromInt(600)) {
+		t.Errorf("wallet balance = %s, want 600", got)
+	}
+
+	// A second rebuild has nothing left to correct for this wallet
+	corrections, err = ledger.RebuildBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range corrections {
+		if c.UserID == user.ID {
+			t.Errorf("second rebuild corrected %+v", c)
+		}
+	}
+	assertLedgerBalanced(t, db)
+}
+
+// TestLedgerRebuildKeepsConcurrentDeposits runs rebuilds while deposits are
+// being made and checks that none of the deposits is rolled back
+func TestLedgerRebuildKeepsConcurrentDeposits(t *testing.T) {
+	ledger, wallets, repos, db := newTestLedgerService(t)
+
+	const deposits = 20
+	user := createTestUser(t, repos.Users)
+	amount := decimal.NewFromInt(10)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < deposits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := wallets.Deposit(user.ID, "IDR", amount); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := ledger.RebuildBalances(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	want := amount.Mul(decimal.NewFromInt(deposits))
+	if got := walletBalance(t, repos, user.ID, "IDR"); !got.Equal(want) {
+		t.Errorf("wallet balance = %s, want %s", got, want)
+	}
+	if got := ledgerBalance(t, repos, user.ID, "IDR"); !got.Equal(want) {
+		t.Errorf("ledger balance = %s, want %s", got, want)
+	}
+	assertLedgerBalanced(t, db)
+}
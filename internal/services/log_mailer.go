@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogMailer does not deliver emails. It logs them with their secrets
+// redacted and, when a directory is configured, also writes each one in full
+// to a file there, so verification and reset links can be picked up during
+// local development and tests.
+type LogMailer struct {
+	dir string
+}
+
+func NewLogMailer(dir string) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create mail log directory: %w", err)
+		}
+	}
+	return &LogMailer{dir: dir}, nil
+}
+
+func (m *LogMailer) Send(ctx context.Context, email Email) error {
+	log.Printf("Email to %s: %s\n%s", email.To, email.Subject, redactSecrets(email.Body, email.Secrets))
+	if m.dir == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s_%s.txt", time.Now().UTC().Format("20060102T150405.000000000"), sanitizeFileName(email.To))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", email.To, email.Subject, email.Body)
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o600)
+}
+
+// redactSecrets masks every secret in the body, also where it appears URL
+// encoded inside a link
+func redactSecrets(body string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		body = strings.ReplaceAll(body, secret, "[REDACTED]")
+		body = strings.ReplaceAll(body, url.QueryEscape(secret), "[REDACTED]")
+	}
+	return body
+}
+
+func sanitizeFileName(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, value)
+}
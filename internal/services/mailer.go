@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"errors"
+	"fmt"
+)
+
+// Email is a plain text message to one recipient. Secrets lists the tokens
+// in the body that must never end up in logs.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+	Secrets []string
+}
+
+// Mailer sends emails. SMTPMailer delivers them; LogMailer only records them
+// for local development and tests.
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+func NewMailerFromConfig(cfg *config.Config) (Mailer, error) {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.Mail), nil
+	case "log":
+		// Logged emails carry working links, so the log driver is for
+		// development only
+		if cfg.Server.Mode == "release" {
+			return nil, errors.New("MAIL_DRIVER=log is not allowed with GIN_MODE=release, configure smtp")
+		}
+		return NewLogMailer(cfg.Mail.LogDir)
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %s", cfg.Mail.Driver)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto-wallet-service/config"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewMailerFromConfigRefusesLogDriverInRelease(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{Mode: "release"},
+		Mail:   config.MailConfig{Driver: "log"},
+	}
+	if _, err := NewMailerFromConfig(cfg); err == nil {
+		t.Error("the log driver was accepted in release mode")
+	}
+
+	cfg.Server.Mode = "debug"
+	if _, err := NewMailerFromConfig(cfg); err != nil {
+		t.Errorf("the log driver was refused in debug mode: %v", err)
+	}
+}
+
+func TestLogMailerRedactsSecrets(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	dir := t.TempDir()
+	mailer, err := NewLogMailer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := "abc+def/ghi="
+	email := Email{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Open http://localhost/reset-password?token=abc%2Bdef%2Fghi%3D\n\nor send " + token,
+		Secrets: []string{token},
+	}
+	if err := mailer.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := output.String()
+	if strings.Contains(logged, "abc") || strings.Count(logged, "[REDACTED]") != 2 {
+		t.Errorf("log output leaks the token: %q", logged)
+	}
+
+	// The file in the mail directory keeps the full email for local use
+	files, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if len(files) != 1 {
+		t.Fatalf("found %d mail files, want 1", len(files))
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil || !strings.Contains(string(content), token) {
+		t.Errorf("mail file = %q, %v, want the full body", content, err)
+	}
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/secrets"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMFAAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrMFASetupNotStarted    = errors.New("two-factor setup has not been started")
+	ErrMFARequired           = errors.New("two-factor code required")
+	ErrMFAEnrollmentRequired = errors.New("two-factor authentication must be enabled for this amount")
+	ErrInvalidMFACode        = errors.New("invalid two-factor code")
+	ErrInvalidMFAChallenge   = errors.New("invalid or expired MFA token")
+	ErrMFATooManyAttempts    = errors.New("too many invalid two-factor codes, try again later")
+	ErrInvalidPassword       = errors.New("invalid password")
+)
+
+const (
+	recoveryCodeCount = 10
+
+	// maxMFAChallengeAttempts bounds the codes tried against one login challenge
+	maxMFAChallengeAttempts = 5
+
+	// After maxMFAFailures wrong codes within mfaFailureWindow every code of
+	// the user is refused until the window passes
+	maxMFAFailures   = 10
+	mfaFailureWindow = 15 * time.Minute
+)
+
+// MFAService implements TOTP two-factor authentication: enrollment with
+// confirmation, hashed single-use recovery codes, the second login step and
+// the step-up check for large withdrawals and transfers.
+type MFAService struct {
+	userRepo         repository.UserRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	redisClient      *redis.Client
+	totpSecrets      *secrets.Box
+
+	issuer            string
+	withdrawThreshold decimal.Decimal
+	requireEnrollment bool
+}
+
+func NewMFAService(
+	userRepo repository.UserRepository,
+	recoveryCodeRepo repository.RecoveryCodeRepository,
+	redisClient *redis.Client,
+	totpSecrets *secrets.Box,
+) *MFAService {
+	return &MFAService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		redisClient:      redisClient,
+		totpSecrets:      totpSecrets,
+
+		issuer:            config.AppConfig.MFA.Issuer,
+		withdrawThreshold: config.AppConfig.MFA.WithdrawThresholdIDR,
+		requireEnrollment: config.AppConfig.MFA.RequireEnrollment,
+	}
+}
+
+func mfaChallengeKey(token string) string {
+	return "mfa:challenge:" + hashToken(token)
+}
+
+func mfaChallengeAttemptsKey(token string) string {
+	return "mfa:challenge_attempts:" + hashToken(token)
+}
+
+func mfaFailuresKey(userID uuid.UUID) string {
+	return fmt.Sprintf("mfa:failures:%s", userID)
+}
+
+// BeginSetup generates a new secret for the user. 2FA is not active until
+// the secret is confirmed with a code; starting again replaces the secret.
+func (s *MFAService) BeginSetup(userID uuid.UUID) (*models.MFASetupResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPSecret, err = s.totpSecrets.Seal(totpSecretAAD(user.ID), secret); err != nil {
+		return nil, err
+	}
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &models.MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totpURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmSetup enables 2FA once the user proves the authenticator works and
+// returns the recovery codes, which are shown only this once
+func (s *MFAService) ConfirmSetup(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFASetupNotStarted
+	}
+
+	if err := s.verify(ctx, user, code, false); err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(userID)
+}
+
+// Disable turns 2FA off. It needs the password and a TOTP or recovery code.
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, password, code string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+
+	if err := s.verify(ctx, user, code, true); err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	return s.recoveryCodeRepo.DeleteForUser(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a TOTP code
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.verify(ctx, user, code, false); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// StartChallenge is the first login step for users with 2FA: instead of
+// tokens they get a short-lived, single-use MFA token
+func (s *MFAService) StartChallenge(ctx context.Context, user *models.User) (*models.MFAChallenge, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := config.GetMFAChallengeTTL()
+	if err := s.redisClient.Set(ctx, mfaChallengeKey(token), user.ID.String(), ttl).Err(); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(ttl.Seconds()),
+	}, nil
+}
+
+// CompleteChallenge is the second login step. It accepts a TOTP code or a
+// recovery code and returns the user to issue tokens for.
+func (s *MFAService) CompleteChallenge(ctx context.Context, token, code string) (*models.User, error) {
+	value, err := s.redisClient.Get(ctx, mfaChallengeKey(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	attempts, err := s.redisClient.Incr(ctx, mfaChallengeAttemptsKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if attempts == 1 {
+		s.redisClient.Expire(ctx, mfaChallengeAttemptsKey(token), config.GetMFAChallengeTTL())
+	}
+	if attempts > maxMFAChallengeAttempts {
+		s.redisClient.Del(ctx, mfaChallengeKey(token), mfaChallengeAttemptsKey(token))
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || !user.TOTPEnabled {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	if err := s.verify(ctx, user, code, true); err != nil {
+		return nil, err
+	}
+
+	// Single use: a second exchange of the same token must fail
+	deleted, err := s.redisClient.Del(ctx, mfaChallengeKey(token), mfaChallengeAttemptsKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	return user, nil
+}
+
+// RequireForWithdrawal checks the step-up code for a withdrawal or transfer
+// worth valueIDR. Amounts up to the configured threshold need no code.
+func (s *MFAService) RequireForWithdrawal(userID uuid.UUID, valueIDR decimal.Decimal, code string) error {
+	if !valueIDR.GreaterThan(s.withdrawThreshold) {
+		return nil
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		if s.requireEnrollment {
+			return ErrMFAEnrollmentRequired
+		}
+		return nil
+	}
+	if strings.TrimSpace(code) == "" {
+		return ErrMFARequired
+	}
+
+	// Only a fresh TOTP code; recovery codes are for regaining access
+	return s.verify(context.Background(), user, code, false)
+}
+
+// verify checks a TOTP code, or a recovery code when allowed. Each TOTP code
+// is accepted once and repeated failures lock the user out for a while.
+func (s *MFAService) verify(ctx context.Context, user *models.User, code string, allowRecovery bool) error {
+	failures, err := s.redisClient.Get(ctx, mfaFailuresKey(user.ID)).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if failures >= maxMFAFailures {
+		return ErrMFATooManyAttempts
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+
+	secret, err := s.totpSecret(user)
+	if err != nil {
+		return err
+	}
+
+	ok := false
+	if step, matched := matchTOTP(secret, code, time.Now()); matched {
+		ok, err = s.userRepo.UpdateTOTPLastStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if ok {
+			user.TOTPLastStep = step
+		}
+	} else if allowRecovery && len(code) > totpDigits {
+		ok, err = s.recoveryCodeRepo.Consume(user.ID, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+	}
+
+	if !ok {
+		pipe := s.redisClient.TxPipeline()
+		pipe.Incr(ctx, mfaFailuresKey(user.ID))
+		pipe.Expire(ctx, mfaFailuresKey(user.ID), mfaFailureWindow)
+		pipe.Exec(ctx)
+		return ErrInvalidMFACode
+	}
+
+	s.redisClient.Del(ctx, mfaFailuresKey(user.ID))
+	return nil
+}
+
+// totpSecret decrypts the user's TOTP secret. The user ID is authenticated
+// too, so a secret cannot be moved to another user.
+func (s *MFAService) totpSecret(user *models.User) (string, error) {
+	secret, err := s.totpSecrets.Open(totpSecretAAD(user.ID), user.TOTPSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+func totpSecretAAD(userID uuid.UUID) []byte {
+	return []byte("totp:" + userID.String())
+}
+
+// newRecoveryCodes stores a fresh set of recovery codes and returns them in
+// plain text, formatted as xxxx-xxxx-xxxx-xxxx
+func (s *MFAService) newRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw, err := generateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		// 16 base32 characters carry 80 bits
+		raw = strings.ToLower(raw[:16])
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)}
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceForUser(userID, rows); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto-wallet-service/internal/secrets"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeUserRepo keeps users in memory. Only the methods used by MFAService
+// are implemented.
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[uuid.UUID]models.User
+}
+
+func (r *fakeUserRepo) FindByID(id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
+
+func (r *fakeUserRepo) Update(user *models.User) error {
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateTOTPLastStep(id uuid.UUID, step int64) (bool, error) {
+	user := r.users[id]
+	if user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	r.users[id] = user
+	return true, nil
+}
+
+func newTestMFAService(t *testing.T) (*MFAService, *fakeUserRepo) {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}}
+	t.Cleanup(func() { config.AppConfig = previous })
+	startTestRedis(t)
+
+	key := make([]byte, secrets.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	box, err := secrets.New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &fakeUserRepo{users: map[uuid.UUID]models.User{}}
+	return NewMFAService(users, nil, config.RedisClient, box), users
+}
+
+func currentTOTPCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totpCode(secret, time.Now().Unix()/int64(totpPeriod.Seconds())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestMFASecretIsEncryptedAtRest(t *testing.T) {
+	service, users := newTestMFAService(t)
+	user := models.User{ID: uuid.New(), Email: "user@example.com"}
+	users.users[user.ID] = user
+
+	setup, err := service.BeginSetup(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := users.users[user.ID].TOTPSecret
+	if stored == "" || stored == setup.Secret {
+		t.Fatalf("stored secret %q is not encrypted", stored)
+	}
+	secret, err := service.totpSecret(&models.User{ID: user.ID, TOTPSecret: stored})
+	if err != nil || secret != setup.Secret {
+		t.Errorf("decrypted %q, %v, want the enrolled secret", secret, err)
+	}
+	if _, err := service.totpSecret(&models.User{ID: uuid.New(), TOTPSecret: stored}); err == nil {
+		t.Error("the secret decrypted for another user")
+	}
+}
+
+func TestMFAVerifyRejectsReplayedCodes(t *testing.T) {
+	service, users := newTestMFAService(t)
+	user := models.User{ID: uuid.New(), Email: "user@example.com"}
+	users.users[user.ID] = user
+
+	setup, err := service.BeginSetup(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(code string) error {
+		current, _ := users.FindByID(user.ID)
+		return service.verify(context.Background(), current, code, false)
+	}
+
+	code := currentTOTPCode(t, setup.Secret, 0)
+	if err := verify(code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := verify(code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replayed code: err = %v, want ErrInvalidMFACode", err)
+	}
+	if err := verify(currentTOTPCode(t, setup.Secret, -1)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("code older than the last used one: err = %v, want ErrInvalidMFACode", err)
+	}
+	if err := verify(currentTOTPCode(t, setup.Secret, 1)); err != nil {
+		t.Errorf("next step within the skew window: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// GetPnL computes cost basis and profit/loss per currency from the user's
+// transaction history. Every credit is an acquisition at its PriceAt, every
+// debit a disposal at its PriceAt, matched against earlier acquisitions with
+// the given method. Fees are disposals without proceeds. The quote currency
+// (IDR) carries no P&L and is left out.
+func (s *WalletService) GetPnL(userID uuid.UUID, method models.CostMethod) (*models.PnLResponse, error) {
+	transactions, err := s.transactionRepo.FindByUserIDUntil(userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	trackers := trackCosts(transactions, method)
+
+	currencies := make([]string, 0, len(trackers))
+	for currency := range trackers {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	response := &models.PnLResponse{
+		Method:                method,
+		Assets:                []models.AssetPnL{},
+		TotalCostBasisIDR:     decimal.Zero,
+		TotalUnrealizedPnLIDR: decimal.Zero,
+		TotalRealizedPnLIDR:   decimal.Zero,
+	}
+
+	for _, currency := range currencies {
+		tracker := trackers[currency]
+
+		price := decimal.Zero
+		if tracker.quantity.IsPositive() {
+			if price, err = s.priceService.GetPrice(currency); err != nil {
+				return nil, fmt.Errorf("failed to get price for %s: %w", currency, err)
+			}
+		}
+
+		asset := tracker.result(currency, price)
+		response.Assets = append(response.Assets, asset)
+		response.TotalCostBasisIDR = response.TotalCostBasisIDR.Add(asset.CostBasisIDR)
+		response.TotalUnrealizedPnLIDR = response.TotalUnrealizedPnLIDR.Add(asset.UnrealizedPnLIDR)
+		response.TotalRealizedPnLIDR = response.TotalRealizedPnLIDR.Add(asset.RealizedPnLIDR)
+	}
+
+	return response, nil
+}
+
+// trackCosts replays the transactions, oldest first, into one cost tracker
+// per currency. The quote currency is left out.
+func trackCosts(transactions []models.Transaction, method models.CostMethod) map[string]*costTracker {
+	trackers := map[string]*costTracker{}
+	for _, tx := range transactions {
+		if tx.Currency == quoteCurrency {
+			continue
+		}
+
+		tracker, ok := trackers[tx.Currency]
+		if !ok {
+			tracker = newCostTracker(method)
+			trackers[tx.Currency] = tracker
+		}
+
+		if tx.SignedAmount().IsPositive() {
+			tracker.acquire(tx.Amount, tx.PriceAt)
+		} else {
+			tracker.dispose(tx.Amount, tx.PriceAt, tx.Type == models.TransactionTypeFee)
+		}
+	}
+	return trackers
+}
+
+// costLot is a quantity acquired at a single unit cost
+type costLot struct {
+	quantity decimal.Decimal
+	unitCost decimal.Decimal
+}
+
+// costTracker follows the holdings of one currency. FIFO and LIFO keep
+// individual lots; average cost only needs the running totals.
+type costTracker struct {
+	method     models.CostMethod
+	lots       []costLot
+	quantity   decimal.Decimal
+	cost       decimal.Decimal
+	realized   decimal.Decimal
+	incomplete bool
+}
+
+func newCostTracker(method models.CostMethod) *costTracker {
+	return &costTracker{method: method}
+}
+
+func (t *costTracker) acquire(quantity, unitCost decimal.Decimal) {
+	if !unitCost.IsPositive() {
+		t.incomplete = true
+	}
+
+	t.quantity = t.quantity.Add(quantity)
+	t.cost = t.cost.Add(quantity.Mul(unitCost))
+	if t.method != models.CostMethodAverage {
+		t.lots = append(t.lots, costLot{quantity: quantity, unitCost: unitCost})
+	}
+}
+
+// dispose removes quantity from the holdings, realizes the difference
+// between proceeds and the cost of the matched units and returns that cost.
+// Disposals without a recorded price remove cost basis but realize nothing.
+// Units disposed of beyond the holdings have no known cost, so they count
+// at zero cost and mark the result incomplete.
+func (t *costTracker) dispose(quantity, unitPrice decimal.Decimal, isFee bool) decimal.Decimal {
+	matched := decimal.Min(quantity, t.quantity)
+	if matched.IsNegative() {
+		matched = decimal.Zero
+	}
+	if quantity.GreaterThan(matched) {
+		t.incomplete = true
+	}
+
+	var cost decimal.Decimal
+	if t.method == models.CostMethodAverage {
+		cost = t.averageCost().Mul(matched)
+		if matched.Equal(t.quantity) {
+			cost = t.cost // avoid leaving rounding residue behind
+		}
+	} else {
+		cost = t.consumeLots(matched)
+	}
+
+	t.quantity = t.quantity.Sub(matched)
+	t.cost = t.cost.Sub(cost)
+
+	switch {
+	case isFee:
+		t.realized = t.realized.Sub(cost)
+	case unitPrice.IsPositive():
+		t.realized = t.realized.Add(quantity.Mul(unitPrice)).Sub(cost)
+	default:
+		t.incomplete = true
+	}
+	return cost
+}
+
+// consumeLots takes quantity from the oldest (FIFO) or newest (LIFO) lots and
+// returns their total cost
+func (t *costTracker) consumeLots(quantity decimal.Decimal) decimal.Decimal {
+	cost := decimal.Zero
+	for quantity.IsPositive() && len(t.lots) > 0 {
+		i := 0
+		if t.method == models.CostMethodLIFO {
+			i = len(t.lots) - 1
+		}
+		lot := &t.lots[i]
+
+		take := decimal.Min(quantity, lot.quantity)
+		cost = cost.Add(take.Mul(lot.unitCost))
+		lot.quantity = lot.quantity.Sub(take)
+		quantity = quantity.Sub(take)
+
+		if lot.quantity.IsZero() {
+			t.lots = append(t.lots[:i], t.lots[i+1:]...)
+		}
+	}
+	return cost
+}
+
+func (t *costTracker) averageCost() decimal.Decimal {
+	if !t.quantity.IsPositive() {
+		return decimal.Zero
+	}
+	return t.cost.Div(t.quantity)
+}
+
+func (t *costTracker) result(currency string, price decimal.Decimal) models.AssetPnL {
+	marketValue := t.quantity.Mul(price)
+	return models.AssetPnL{
+		Currency:            currency,
+		Quantity:            t.quantity,
+		CostBasisIDR:        t.cost.Round(2),
+		AverageCostIDR:      t.averageCost().Round(2),
+		PriceIDR:            price,
+		MarketValueIDR:      marketValue.Round(2),
+		UnrealizedPnLIDR:    marketValue.Sub(t.cost).Round(2),
+		RealizedPnLIDR:      t.realized.Round(2),
+		IncompleteCostBasis: t.incomplete,
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// costStep is one acquisition or disposal fed to a cost tracker
+type costStep struct {
+	dispose  bool
+	fee      bool
+	quantity string
+	price    string
+}
+
+func buy(quantity, price string) costStep {
+	return costStep{quantity: quantity, price: price}
+}
+
+func sell(quantity, price string) costStep {
+	return costStep{dispose: true, quantity: quantity, price: price}
+}
+
+func fee(quantity string) costStep {
+	return costStep{dispose: true, fee: true, quantity: quantity, price: "0"}
+}
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestCostTracker(t *testing.T) {
+	// Three lots: 1 @ 100, 1 @ 200, 2 @ 300, then a sale spanning them
+	lots := []costStep{buy("1", "100"), buy("1", "200"), buy("2", "300")}
+
+	tests := []struct {
+		name       string
+		method     models.CostMethod
+		steps      []costStep
+		quantity   string
+		costBasis  string
+		realized   string
+		incomplete bool
+	}{
+		{
+			name:      "fifo sell spanning several lots",
+			method:    models.CostMethodFIFO,
+			steps:     append(lots, sell("2.5", "400")),
+			quantity:  "1.5",
+			costBasis: "450", // 1.5 left of the 300 lot
+			realized:  "550", // 1000 - (100 + 200 + 0.5 x 300)
+		},
+		{
+			name:      "lifo sell spanning several lots",
+			method:    models.CostMethodLIFO,
+			steps:     append(lots, sell("2.5", "400")),
+			quantity:  "1.5",
+			costBasis: "200", // 1 @ 100 + 0.5 @ 200
+			realized:  "300", // 1000 - (2 x 300 + 0.5 x 200)
+		},
+		{
+			name:      "average sell",
+			method:    models.CostMethodAverage,
+			steps:     append(lots, sell("2.5", "400")),
+			quantity:  "1.5",
+			costBasis: "337.5", // 1.5 x 225
+			realized:  "437.5", // 1000 - 2.5 x 225
+		},
+		{
+			name:      "fifo partial lot consumed by two sales",
+			method:    models.CostMethodFIFO,
+			steps:     []costStep{buy("2", "100"), buy("1", "400"), sell("0.5", "200"), sell("2", "500")},
+			quantity:  "0.5",
+			costBasis: "200", // 0.5 left of the 400 lot
+			realized:  "700", // (100 - 50) + (1000 - (150 + 200))
+		},
+		{
+			name:       "fifo sell larger than holdings",
+			method:     models.CostMethodFIFO,
+			steps:      []costStep{buy("1", "100"), sell("3", "150")},
+			quantity:   "0",
+			costBasis:  "0",
+			realized:   "350", // 450 proceeds, only the held unit has a cost
+			incomplete: true,
+		},
+		{
+			name:       "average sell larger than holdings",
+			method:     models.CostMethodAverage,
+			steps:      []costStep{buy("2", "100"), sell("3", "150")},
+			quantity:   "0",
+			costBasis:  "0",
+			realized:   "250",
+			incomplete: true,
+		},
+		{
+			name:      "fifo fee is a loss without proceeds",
+			method:    models.CostMethodFIFO,
+			steps:     []costStep{buy("1", "100"), buy("1", "200"), fee("1.5")},
+			quantity:  "0.5",
+			costBasis: "100",
+			realized:  "-200", // 100 + 0.5 x 200
+		},
+		{
+			name:      "lifo fee takes the newest lot",
+			method:    models.CostMethodLIFO,
+			steps:     []costStep{buy("1", "100"), buy("1", "200"), fee("0.5")},
+			quantity:  "1.5",
+			costBasis: "200",
+			realized:  "-100",
+		},
+		{
+			name:      "average fee",
+			method:    models.CostMethodAverage,
+			steps:     []costStep{buy("1", "100"), buy("1", "200"), fee("0.5")},
+			quantity:  "1.5",
+			costBasis: "225",
+			realized:  "-75",
+		},
+		{
+			name:       "acquisition without price",
+			method:     models.CostMethodFIFO,
+			steps:      []costStep{buy("1", "0"), buy("1", "100")},
+			quantity:   "2",
+			costBasis:  "100",
+			realized:   "0",
+			incomplete: true,
+		},
+		{
+			name:       "disposal without price realizes nothing",
+			method:     models.CostMethodFIFO,
+			steps:      []costStep{buy("2", "100"), sell("1", "0")},
+			quantity:   "1",
+			costBasis:  "100",
+			realized:   "0",
+			incomplete: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newCostTracker(tt.method)
+			for _, step := range tt.steps {
+				if step.dispose {
+					tracker.dispose(dec(step.quantity), dec(step.price), step.fee)
+				} else {
+					tracker.acquire(dec(step.quantity), dec(step.price))
+				}
+			}
+
+			if !tracker.quantity.Equal(dec(tt.quantity)) {
+				t.Errorf("quantity = %s, want %s", tracker.quantity, tt.quantity)
+			}
+			if !tracker.cost.Equal(dec(tt.costBasis)) {
+				t.Errorf("cost basis = %s, want %s", tracker.cost, tt.costBasis)
+			}
+			if !tracker.realized.Equal(dec(tt.realized)) {
+				t.Errorf("realized = %s, want %s", tracker.realized, tt.realized)
+			}
+			if tracker.incomplete != tt.incomplete {
+				t.Errorf("incomplete = %v, want %v", tracker.incomplete, tt.incomplete)
+			}
+		})
+	}
+}
+
+func TestCostTrackerDisposeReturnsMatchedCost(t *testing.T) {
+	tracker := newCostTracker(models.CostMethodFIFO)
+	tracker.acquire(dec("1"), dec("100"))
+	tracker.acquire(dec("1"), dec("200"))
+
+	if cost := tracker.dispose(dec("1.5"), dec("300"), false); !cost.Equal(dec("200")) {
+		t.Errorf("cost = %s, want 200", cost)
+	}
+}
+
+func TestTrackCostsTransfersAndFees(t *testing.T) {
+	transactions := []models.Transaction{
+		{Type: models.TransactionTypeDeposit, Direction: models.TransactionDirectionCredit, Currency: "BTC", Amount: dec("1"), PriceAt: dec("100")},
+		{Type: models.TransactionTypeTransfer, Direction: models.TransactionDirectionCredit, Currency: "BTC", Amount: dec("1"), PriceAt: dec("200")},
+		{Type: models.TransactionTypeTransfer, Direction: models.TransactionDirectionDebit, Currency: "BTC", Amount: dec("1"), PriceAt: dec("300")},
+		{Type: models.TransactionTypeFee, Direction: models.TransactionDirectionDebit, Currency: "BTC", Amount: dec("0.1"), PriceAt: dec("300")},
+		{Type: models.TransactionTypeDeposit, Direction: models.TransactionDirectionCredit, Currency: "IDR", Amount: dec("5000"), PriceAt: dec("1")},
+	}
+
+	trackers := trackCosts(transactions, models.CostMethodFIFO)
+
+	if _, ok := trackers["IDR"]; ok {
+		t.Error("the quote currency must not be tracked")
+	}
+	btc, ok := trackers["BTC"]
+	if !ok {
+		t.Fatal("BTC is not tracked")
+	}
+
+	// The incoming transfer is an acquisition and the outgoing one a
+	// disposal: 300 - 100 realized, then the fee costs 0.1 x 200
+	result := btc.result("BTC", dec("400"))
+	want := models.AssetPnL{
+		Quantity:         dec("0.9"),
+		CostBasisIDR:     dec("180"),
+		MarketValueIDR:   dec("360"),
+		UnrealizedPnLIDR: dec("180"),
+		RealizedPnLIDR:   dec("180"),
+	}
+	if !result.Quantity.Equal(want.Quantity) ||
+		!result.CostBasisIDR.Equal(want.CostBasisIDR) ||
+		!result.MarketValueIDR.Equal(want.MarketValueIDR) ||
+		!result.UnrealizedPnLIDR.Equal(want.UnrealizedPnLIDR) ||
+		!result.RealizedPnLIDR.Equal(want.RealizedPnLIDR) {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidHistoryRange = errors.New("invalid history range")
+
+// maxHistoryPoints bounds the size of a single history response
+const maxHistoryPoints = 1000
+
+var historyIntervals = map[string]time.Duration{
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+	"1w": 7 * 24 * time.Hour,
+}
+
+// GetPortfolioHistory rebuilds the user's balances from the transaction
+// history and values them at the end of every interval between from and to.
+// Each currency is valued with the latest price known at that moment: the
+// recorded price history, or the price stored on the user's own transactions
+// when that is more recent.
+func (s *WalletService) GetPortfolioHistory(userID uuid.UUID, from, to time.Time, interval string) (*models.PortfolioHistoryResponse, error) {
+	step, ok := historyIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported interval %q", ErrInvalidHistoryRange, interval)
+	}
+
+	if now := time.Now().UTC(); to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidHistoryRange)
+	}
+
+	count := int((to.Sub(from) + step - 1) / step)
+	if count > maxHistoryPoints {
+		return nil, fmt.Errorf("%w: range exceeds %d points, use a larger interval", ErrInvalidHistoryRange, maxHistoryPoints)
+	}
+
+	transactions, err := s.transactionRepo.FindByUserIDUntil(userID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var currencies []string
+	seen := map[string]bool{}
+	for _, tx := range transactions {
+		if !seen[tx.Currency] {
+			seen[tx.Currency] = true
+			currencies = append(currencies, tx.Currency)
+		}
+	}
+	sort.Strings(currencies)
+
+	var priced []string
+	for _, currency := range currencies {
+		if currency != quoteCurrency {
+			priced = append(priced, currency)
+		}
+	}
+
+	history, err := s.priceService.History(priced, from, to, step)
+	if err != nil {
+		return nil, err
+	}
+	prices := buildPriceSeries(history, transactions)
+
+	response := &models.PortfolioHistoryResponse{
+		From:     from,
+		To:       to,
+		Interval: interval,
+		Points:   make([]models.PortfolioHistoryPoint, 0, count),
+	}
+
+	balances := make(map[string]decimal.Decimal, len(currencies))
+	next := 0
+	for i := 1; i <= count; i++ {
+		at := from.Add(time.Duration(i) * step)
+		if at.After(to) {
+			at = to
+		}
+
+		for next < len(transactions) && !transactions[next].CreatedAt.After(at) {
+			tx := transactions[next]
+			if tx.AffectsBalance() {
+				balances[tx.Currency] = balances[tx.Currency].Add(tx.SignedAmount())
+			}
+			next++
+		}
+
+		point := models.PortfolioHistoryPoint{
+			Timestamp:     at,
+			Assets:        []models.WalletWithPrice{},
+			TotalValueIDR: decimal.Zero,
+		}
+
+		for _, currency := range currencies {
+			balance := balances[currency]
+			if balance.IsZero() {
+				continue
+			}
+
+			price, ok := prices.at(currency, at)
+			if !ok {
+				point.MissingPrices = append(point.MissingPrices, currency)
+			}
+
+			value := balance.Mul(price).Round(2)
+			point.Assets = append(point.Assets, models.WalletWithPrice{
+				Currency: currency,
+				Balance:  balance,
+				PriceIDR: price,
+				ValueIDR: value,
+			})
+			point.TotalValueIDR = point.TotalValueIDR.Add(value)
+		}
+
+		response.Points = append(response.Points, point)
+	}
+
+	return response, nil
+}
+
+type pricePoint struct {
+	at    time.Time
+	price decimal.Decimal
+}
+
+// priceSeries holds the known prices per currency, oldest first
+type priceSeries map[string][]pricePoint
+
+func buildPriceSeries(history map[string][]models.PriceHistory, transactions []models.Transaction) priceSeries {
+	series := priceSeries{}
+	for currency, prices := range history {
+		for _, p := range prices {
+			series[currency] = append(series[currency], pricePoint{at: p.RecordedAt, price: p.PriceIDR})
+		}
+	}
+
+	for _, tx := range transactions {
+		if tx.Currency != quoteCurrency && tx.PriceAt.IsPositive() {
+			series[tx.Currency] = append(series[tx.Currency], pricePoint{at: tx.CreatedAt, price: tx.PriceAt})
+		}
+	}
+
+	for _, points := range series {
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].at.Before(points[j].at)
+		})
+	}
+	return series
+}
+
+// at returns the latest price recorded at or before t
+func (s priceSeries) at(currency string, t time.Time) (decimal.Decimal, bool) {
+	if currency == quoteCurrency {
+		return decimal.NewFromInt(1), true
+	}
+
+	points := s[currency]
+	i := sort.Search(len(points), func(i int) bool {
+		return points[i].at.After(t)
+	})
+	if i == 0 {
+		return decimal.Zero, false
+	}
+	return points[i-1].price, true
+}
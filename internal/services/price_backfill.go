@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PriceBackfillSource marks price history rows loaded by the backfill
+const PriceBackfillSource = "coingecko_market_chart"
+
+// PriceBackfillService loads historical daily prices into the price history
+// so valuations reach back before the service started recording prices.
+type PriceBackfillService struct {
+	provider         *CoinGeckoProvider
+	assets           *AssetRegistry
+	priceHistoryRepo repository.PriceHistoryRepository
+}
+
+func NewPriceBackfillService(
+	provider *CoinGeckoProvider,
+	assets *AssetRegistry,
+	priceHistoryRepo repository.PriceHistoryRepository,
+) *PriceBackfillService {
+	return &PriceBackfillService{
+		provider:         provider,
+		assets:           assets,
+		priceHistoryRepo: priceHistoryRepo,
+	}
+}
+
+// Backfill loads one IDR price per UTC day for the last days of the symbol,
+// recorded at midnight UTC. The current, unfinished day is skipped and days
+// already backfilled are left alone, so the backfill can be rerun safely. It
+// returns how many rows were added.
+func (s *PriceBackfillService) Backfill(ctx context.Context, symbol string, days int) (int, error) {
+	if _, ok := s.assets.Get(symbol); !ok || symbol == quoteCurrency {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, symbol)
+	}
+
+	points, err := s.provider.GetDailyHistory(ctx, symbol, quoteCurrency, days)
+	var rateLimited *RateLimitError
+	if errors.As(err, &rateLimited) {
+		wait := rateLimited.RetryAfter
+		if wait <= 0 {
+			wait = time.Minute
+		}
+		log.Printf("CoinGecko rate limit hit, retrying %s in %s", symbol, wait)
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(wait):
+		}
+		points, err = s.provider.GetDailyHistory(ctx, symbol, quoteCurrency, days)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep the last point of every finished day
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	daily := map[int64]models.PriceHistory{}
+	for _, point := range points {
+		day := point.Time.Truncate(24 * time.Hour)
+		if !day.Before(today) || !point.Price.IsPositive() {
+			continue
+		}
+		daily[day.Unix()] = models.PriceHistory{
+			Currency:   symbol,
+			PriceIDR:   point.Price,
+			Source:     PriceBackfillSource,
+			RecordedAt: day,
+		}
+	}
+	if len(daily) == 0 {
+		return 0, nil
+	}
+
+	existing, err := s.priceHistoryRepo.FindRecordedTimes(symbol, PriceBackfillSource, today.AddDate(0, 0, -days-1), today)
+	if err != nil {
+		return 0, err
+	}
+	for _, recordedAt := range existing {
+		delete(daily, recordedAt.Unix())
+	}
+
+	rows := make([]models.PriceHistory, 0, len(daily))
+	for _, row := range daily {
+		rows = append(rows, row)
+	}
+	if err := s.priceHistoryRepo.CreateBatch(rows); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
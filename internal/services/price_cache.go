@@ -0,0 +1,72 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type cachedPrice struct {
+	price     decimal.Decimal
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+// localPriceCache is the small in-process cache in front of Redis. Entries
+// live only a few seconds so instances never drift far from the shared cache.
+type localPriceCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]cachedPrice
+}
+
+func newLocalPriceCache(ttl time.Duration) *localPriceCache {
+	return &localPriceCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedPrice),
+	}
+}
+
+func (c *localPriceCache) get(key string) (cachedPrice, bool) {
+	if c.ttl <= 0 {
+		return cachedPrice{}, false
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return cachedPrice{}, false
+	}
+	return entry, true
+}
+
+func (c *localPriceCache) set(key string, price decimal.Decimal, fetchedAt time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.entries[key] = cachedPrice{price: price, fetchedAt: fetchedAt, expiresAt: now.Add(c.ttl)}
+
+	// The key space is bounded by assets x vs currencies, so an occasional
+	// sweep is enough to keep expired entries from piling up
+	if len(c.entries) > 1000 {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+func (c *localPriceCache) clear() {
+	c.mu.Lock()
+	c.entries = make(map[string]cachedPrice)
+	c.mu.Unlock()
+}
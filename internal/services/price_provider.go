@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceProvider fetches spot prices for currency symbols (BTC, ETH, ...)
+// quoted in vs (e.g. "idr"). Symbols the provider cannot price are simply
+// missing from the result; an error means the provider could not be queried.
+type PriceProvider interface {
+	Name() string
+	GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error)
+}
+
+// RateLimitError is returned by a provider that was told to slow down
+type RateLimitError struct {
+	Provider   string
+	RetryAfter time.Duration // zero when the provider did not say
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Provider, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s rate limit exceeded", e.Provider)
+}
+
+func newRateLimitError(provider string, resp *http.Response) *RateLimitError {
+	err := &RateLimitError{Provider: provider}
+
+	value := resp.Header.Get("Retry-After")
+	if seconds, parseErr := strconv.Atoi(value); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	} else if at, parseErr := http.ParseTime(value); parseErr == nil {
+		err.RetryAfter = time.Until(at)
+	}
+	if err.RetryAfter < 0 {
+		err.RetryAfter = 0
+	}
+	return err
+}
+
+// ProviderIDLookup maps a currency symbol to the id a price provider uses for it
+type ProviderIDLookup interface {
+	ProviderID(symbol string) (string, bool)
+}
+
+const (
+	PriceModeFailover = "failover"
+	PriceModeMedian   = "median"
+)
+
+// MultiPriceProvider combines several providers. In failover mode providers
+// are asked in priority order and later ones only fill in the symbols that
+// earlier ones failed to price. In median mode every provider is asked and
+// the median quote per symbol is used, so a single bad feed cannot move the
+// price on its own.
+type MultiPriceProvider struct {
+	providers  []PriceProvider
+	mode       string
+	minSources int
+}
+
+func NewMultiPriceProvider(providers []PriceProvider, mode string, minSources int) *MultiPriceProvider {
+	if minSources < 1 {
+		minSources = 1
+	}
+	return &MultiPriceProvider{
+		providers:  providers,
+		mode:       mode,
+		minSources: minSources,
+	}
+}
+
+func (p *MultiPriceProvider) Name() string {
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.Name()
+	}
+	return p.mode + "(" + strings.Join(names, ",") + ")"
+}
+
+func (p *MultiPriceProvider) GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	if p.mode == PriceModeMedian {
+		return p.median(ctx, symbols, vs)
+	}
+	return p.failover(ctx, symbols, vs)
+}
+
+func (p *MultiPriceProvider) failover(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	prices := make(map[string]decimal.Decimal, len(symbols))
+	missing := symbols
+	var errs []error
+
+	for _, provider := range p.providers {
+		if len(missing) == 0 {
+			break
+		}
+
+		result, err := provider.GetPrices(ctx, missing, vs)
+		if err != nil {
+			log.Printf("Price provider %s failed: %v", provider.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+
+		var stillMissing []string
+		for _, symbol := range missing {
+			if price, ok := result[symbol]; ok && price.IsPositive() {
+				prices[symbol] = price
+			} else {
+				stillMissing = append(stillMissing, symbol)
+			}
+		}
+		missing = stillMissing
+	}
+
+	if len(missing) > 0 {
+		return prices, missingPricesError(missing, errs)
+	}
+	return prices, nil
+}
+
+func (p *MultiPriceProvider) median(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	results := make([]map[string]decimal.Decimal, len(p.providers))
+	errs := make([]error, len(p.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range p.providers {
+		wg.Add(1)
+		go func(i int, provider PriceProvider) {
+			defer wg.Done()
+			result, err := provider.GetPrices(ctx, symbols, vs)
+			if err != nil {
+				log.Printf("Price provider %s failed: %v", provider.Name(), err)
+				errs[i] = fmt.Errorf("%s: %w", provider.Name(), err)
+				return
+			}
+			results[i] = result
+		}(i, provider)
+	}
+	wg.Wait()
+
+	// Never require more sources than there are providers
+	minSources := p.minSources
+	if minSources > len(p.providers) {
+		minSources = len(p.providers)
+	}
+
+	prices := make(map[string]decimal.Decimal, len(symbols))
+	var missing []string
+	for _, symbol := range symbols {
+		var quotes []decimal.Decimal
+		for _, result := range results {
+			if price, ok := result[symbol]; ok && price.IsPositive() {
+				quotes = append(quotes, price)
+			}
+		}
+		if len(quotes) < minSources {
+			missing = append(missing, symbol)
+			continue
+		}
+		prices[symbol] = medianOf(quotes)
+	}
+
+	if len(missing) > 0 {
+		return prices, missingPricesError(missing, errs)
+	}
+	return prices, nil
+}
+
+func medianOf(values []decimal.Decimal) decimal.Decimal {
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].LessThan(values[j])
+	})
+
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return values[mid-1].Add(values[mid]).Div(decimal.NewFromInt(2))
+}
+
+func missingPricesError(missing []string, errs []error) error {
+	err := fmt.Errorf("no price available for: %s", strings.Join(missing, ", "))
+	if joined := errors.Join(errs...); joined != nil {
+		return fmt.Errorf("%w (%w)", err, joined)
+	}
+	return err
+}
+
+// NewPriceProviderFromConfig builds the provider chain listed in PRICE_PROVIDERS
+func NewPriceProviderFromConfig(cfg *config.Config, ids ProviderIDLookup) (PriceProvider, error) {
+	var providers []PriceProvider
+	for _, name := range cfg.Price.Providers {
+		switch name {
+		case "coingecko":
+			providers = append(providers, NewCoinGeckoProvider(cfg.CoinGecko.APIURL, ids))
+		case "binance":
+			provider, err := NewBinanceProvider(cfg.Price.BinanceAPIURL, cfg.Price.BinanceQuotes, ids)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case "static":
+			provider, err := NewStaticProvider(cfg.Price.StaticFile)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		default:
+			return nil, fmt.Errorf("unknown price provider: %s", name)
+		}
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("no price providers configured")
+	}
+
+	switch cfg.Price.Mode {
+	case PriceModeFailover, PriceModeMedian:
+	default:
+		return nil, fmt.Errorf("unknown price mode: %s", cfg.Price.Mode)
+	}
+
+	return NewMultiPriceProvider(providers, cfg.Price.Mode, cfg.Price.MedianMinSources), nil
+}
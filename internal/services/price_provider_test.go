@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// fakePriceProvider returns fixed prices, or err when set, and records the
+// symbols it was asked for
+type fakePriceProvider struct {
+	name   string
+	prices map[string]string
+	err    error
+	asked  []string
+}
+
+func (p *fakePriceProvider) Name() string {
+	return p.name
+}
+
+func (p *fakePriceProvider) GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	p.asked = append([]string(nil), symbols...)
+	if p.err != nil {
+		return nil, p.err
+	}
+	prices := make(map[string]decimal.Decimal)
+	for _, symbol := range symbols {
+		if value, ok := p.prices[symbol]; ok {
+			prices[symbol] = decimal.RequireFromString(value)
+		}
+	}
+	return prices, nil
+}
+
+func assertPrices(t *testing.T, got map[string]decimal.Decimal, want map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %d prices %v, want %v", len(got), got, want)
+	}
+	for symbol, value := range want {
+		if price, ok := got[symbol]; !ok || !price.Equal(decimal.RequireFromString(value)) {
+			t.Errorf("%s = %v, want %s", symbol, got[symbol], value)
+		}
+	}
+}
+
+func TestFailoverUsesNextProviderWhenOneFails(t *testing.T) {
+	down := &fakePriceProvider{name: "down", err: errors.New("connection refused")}
+	backup := &fakePriceProvider{name: "backup", prices: map[string]string{"BTC": "100", "ETH": "10"}}
+	provider := NewMultiPriceProvider([]PriceProvider{down, backup}, PriceModeFailover, 1)
+
+	prices, err := provider.GetPrices(context.Background(), []string{"BTC", "ETH"}, "idr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPrices(t, prices, map[string]string{"BTC": "100", "ETH": "10"})
+}
+
+func TestFailoverOnlyAsksLaterProvidersForMissingSymbols(t *testing.T) {
+	primary := &fakePriceProvider{name: "primary", prices: map[string]string{"BTC": "100", "ETH": "0"}}
+	backup := &fakePriceProvider{name: "backup", prices: map[string]string{"BTC": "999", "ETH": "10"}}
+	provider := NewMultiPriceProvider([]PriceProvider{primary, backup}, PriceModeFailover, 1)
+
+	prices, err := provider.GetPrices(context.Background(), []string{"BTC", "ETH"}, "idr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A zero price counts as missing
+	assertPrices(t, prices, map[string]string{"BTC": "100", "ETH": "10"})
+	if len(backup.asked) != 1 || backup.asked[0] != "ETH" {
+		t.Errorf("backup was asked for %v, want [ETH]", backup.asked)
+	}
+}
+
+func TestFailoverAllProvidersFail(t *testing.T) {
+	first := &fakePriceProvider{name: "first", err: errors.New("timeout")}
+	second := &fakePriceProvider{name: "second", err: errors.New("rate limited")}
+	provider := NewMultiPriceProvider([]PriceProvider{first, second}, PriceModeFailover, 1)
+
+	prices, err := provider.GetPrices(context.Background(), []string{"BTC"}, "idr")
+	if err == nil {
+		t.Fatal("expected an error when every provider fails")
+	}
+	if len(prices) != 0 {
+		t.Errorf("got prices %v, want none", prices)
+	}
+	for _, want := range []string{"BTC", "first: timeout", "second: rate limited"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestFailoverReturnsPartialPricesWithError(t *testing.T) {
+	only := &fakePriceProvider{name: "only", prices: map[string]string{"BTC": "100"}}
+	provider := NewMultiPriceProvider([]PriceProvider{only}, PriceModeFailover, 1)
+
+	prices, err := provider.GetPrices(context.Background(), []string{"BTC", "DOGE"}, "idr")
+	if err == nil || !strings.Contains(err.Error(), "DOGE") {
+		t.Errorf("error = %v, want one naming DOGE", err)
+	}
+	assertPrices(t, prices, map[string]string{"BTC": "100"})
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name       string
+		providers  []*fakePriceProvider
+		minSources int
+		want       map[string]string
+		wantErr    bool
+	}{
+		{
+			name: "odd number of quotes",
+			providers: []*fakePriceProvider{
+				{name: "a", prices: map[string]string{"BTC": "100"}},
+				{name: "b", prices: map[string]string{"BTC": "300"}},
+				{name: "c", prices: map[string]string{"BTC": "110"}},
+			},
+			minSources: 2,
+			want:       map[string]string{"BTC": "110"},
+		},
+		{
+			name: "even number of quotes averages the middle two",
+			providers: []*fakePriceProvider{
+				{name: "a", prices: map[string]string{"BTC": "100"}},
+				{name: "b", prices: map[string]string{"BTC": "1000"}},
+				{name: "c", prices: map[string]string{"BTC": "105"}},
+				{name: "d", prices: map[string]string{"BTC": "90"}},
+			},
+			minSources: 2,
+			want:       map[string]string{"BTC": "102.5"},
+		},
+		{
+			name: "failed provider is ignored",
+			providers: []*fakePriceProvider{
+				{name: "a", prices: map[string]string{"BTC": "100"}},
+				{name: "b", err: errors.New("timeout")},
+				{name: "c", prices: map[string]string{"BTC": "101"}},
+			},
+			minSources: 2,
+			want:       map[string]string{"BTC": "100.5"},
+		},
+		{
+			name: "too few sources",
+			providers: []*fakePriceProvider{
+				{name: "a", prices: map[string]string{"BTC": "100"}},
+				{name: "b", err: errors.New("timeout")},
+			},
+			minSources: 2,
+			want:       map[string]string{},
+			wantErr:    true,
+		},
+		{
+			name: "min sources capped at provider count",
+			providers: []*fakePriceProvider{
+				{name: "a", prices: map[string]string{"BTC": "100"}},
+			},
+			minSources: 3,
+			want:       map[string]string{"BTC": "100"},
+		},
+		{
+			name: "every provider fails",
+			providers: []*fakePriceProvider{
+				{name: "a", err: errors.New("timeout")},
+				{name: "b", err: errors.New("timeout")},
+			},
+			minSources: 1,
+			want:       map[string]string{},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := make([]PriceProvider, len(tt.providers))
+			for i, p := range tt.providers {
+				providers[i] = p
+			}
+			provider := NewMultiPriceProvider(providers, PriceModeMedian, tt.minSources)
+
+			prices, err := provider.GetPrices(context.Background(), []string{"BTC"}, "idr")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			assertPrices(t, prices, tt.want)
+		})
+	}
+}
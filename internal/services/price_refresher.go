@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const priceRefresherLockKey = "price_refresher:lock"
+
+// PriceRefresher keeps the price cache warm by refreshing every enabled
+// asset on a fixed interval, so requests rarely have to wait for the
+// provider. Each refresh also lands in the price history.
+//
+// Only one instance refreshes per interval: the run takes a Redis lock that
+// expires shortly before the next one is due. Failed runs back off
+// exponentially up to a maximum, and a rate limited provider is not asked
+// again before its Retry-After. Every delay gets some jitter so instances do
+// not line up.
+type PriceRefresher struct {
+	priceService *PriceService
+	redisClient  *redis.Client
+
+	interval   time.Duration
+	maxBackoff time.Duration
+
+	mu     sync.RWMutex
+	status models.PriceRefresherStatus
+}
+
+func NewPriceRefresher(priceService *PriceService, redisClient *redis.Client) *PriceRefresher {
+	interval := time.Duration(config.AppConfig.Price.RefreshSeconds) * time.Second
+	maxBackoff := time.Duration(config.AppConfig.Price.RefreshMaxBackoffSeconds) * time.Second
+	if maxBackoff < interval {
+		maxBackoff = interval
+	}
+
+	return &PriceRefresher{
+		priceService: priceService,
+		redisClient:  redisClient,
+		interval:     interval,
+		maxBackoff:   maxBackoff,
+		status: models.PriceRefresherStatus{
+			Enabled: interval > 0,
+		},
+	}
+}
+
+// Start runs the refresher until ctx is done. It does nothing when the
+// interval is zero.
+func (r *PriceRefresher) Start(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	r.status.Interval = r.interval.String()
+	r.mu.Unlock()
+
+	go func() {
+		delay := withJitter(time.Second)
+		for {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			delay = r.run(ctx)
+		}
+	}()
+}
+
+// Status returns a copy of the latest run status
+func (r *PriceRefresher) Status() models.PriceRefresherStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+// run refreshes once and returns how long to wait before the next run
+func (r *PriceRefresher) run(ctx context.Context) time.Duration {
+	now := time.Now().UTC()
+
+	acquired, err := r.redisClient.SetNX(ctx, priceRefresherLockKey, now.Format(time.RFC3339), r.interval*9/10).Result()
+	if err != nil {
+		// Without Redis there is no coordination; refreshing anyway is better
+		// than letting the cache go stale
+		log.Printf("Price refresher could not take the lock: %v", err)
+		acquired = true
+	}
+	if !acquired {
+		delay := withJitter(r.interval)
+		r.update(func(status *models.PriceRefresherStatus) {
+			status.Skipped = true
+			status.NextRunAt = timePtr(now.Add(delay))
+		})
+		return delay
+	}
+
+	refreshCtx, cancel := context.WithTimeout(ctx, r.interval)
+	count, err := r.priceService.Refresh(refreshCtx)
+	cancel()
+
+	var delay time.Duration
+	r.update(func(status *models.PriceRefresherStatus) {
+		status.LastRunAt = timePtr(now)
+		status.PricesRefreshed = count
+		status.Skipped = false
+		status.LastError = ""
+		if err != nil {
+			status.LastError = err.Error()
+		}
+
+		// A partial refresh still counts as a success; the assets that could
+		// not be priced are reported in last_error
+		if count > 0 || err == nil {
+			status.ConsecutiveFailures = 0
+			status.LastSuccessAt = timePtr(now)
+			delay = r.interval
+		} else {
+			status.ConsecutiveFailures++
+			delay = r.backoff(status.ConsecutiveFailures)
+		}
+
+		var rateLimited *RateLimitError
+		if errors.As(err, &rateLimited) && rateLimited.RetryAfter > delay {
+			delay = rateLimited.RetryAfter
+		}
+
+		delay = withJitter(delay)
+		status.NextRunAt = timePtr(now.Add(delay))
+	})
+
+	if err != nil {
+		log.Printf("Price refresh priced %d assets: %v", count, err)
+	}
+	return delay
+}
+
+// backoff doubles the interval for every consecutive failure, up to the
+// configured maximum
+func (r *PriceRefresher) backoff(failures int) time.Duration {
+	delay := r.interval
+	for i := 1; i < failures && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
+
+func (r *PriceRefresher) update(fn func(status *models.PriceRefresherStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.status)
+}
+
+// withJitter adds up to 10% to d
+func withJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/10+1))
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestPriceRefresher(t *testing.T, provider *recordingPriceProvider) (*PriceRefresher, *miniredis.Miniredis) {
+	t.Helper()
+
+	service, redisServer, _ := newTestPriceService(t, provider)
+	config.AppConfig.Price.RefreshSeconds = 60
+	config.AppConfig.Price.RefreshMaxBackoffSeconds = 600
+	return NewPriceRefresher(service, config.RedisClient), redisServer
+}
+
+// assertDelay checks that delay is want plus at most 10% jitter
+func assertDelay(t *testing.T, delay, want time.Duration) {
+	t.Helper()
+	if delay < want || delay > want+want/10 {
+		t.Errorf("delay = %s, want %s plus up to 10%% jitter", delay, want)
+	}
+}
+
+func TestPriceRefresherBackoffDoublesUpToMaximum(t *testing.T) {
+	refresher, _ := newTestPriceRefresher(t, &recordingPriceProvider{})
+
+	want := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		4:  8 * time.Minute,
+		5:  10 * time.Minute,
+		20: 10 * time.Minute,
+	}
+	for failures, delay := range want {
+		if got := refresher.backoff(failures); got != delay {
+			t.Errorf("backoff(%d) = %s, want %s", failures, got, delay)
+		}
+	}
+}
+
+func TestPriceRefresherStatusTracksFailures(t *testing.T) {
+	provider := &recordingPriceProvider{prices: map[string]string{"BTC": "1000", "ETH": "50"}}
+	refresher, redisServer := newTestPriceRefresher(t, provider)
+	ctx := context.Background()
+
+	assertDelay(t, refresher.run(ctx), time.Minute)
+	status := refresher.Status()
+	if status.PricesRefreshed != 2 || status.ConsecutiveFailures != 0 || status.LastSuccessAt == nil || status.LastError != "" {
+		t.Errorf("status after success = %+v", status)
+	}
+	if ttl := redisServer.TTL(priceRefresherLockKey); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("lock TTL = %s, want it to expire before the next run", ttl)
+	}
+	lastSuccess := *status.LastSuccessAt
+
+	provider.err = errors.New("provider down")
+	for failures := 1; failures <= 2; failures++ {
+		redisServer.Del(priceRefresherLockKey)
+		assertDelay(t, refresher.run(ctx), refresher.backoff(failures))
+	}
+	status = refresher.Status()
+	if status.ConsecutiveFailures != 2 || status.LastError == "" || !status.LastSuccessAt.Equal(lastSuccess) {
+		t.Errorf("status after failures = %+v", status)
+	}
+
+	// A rate limited provider is not asked again before its Retry-After
+	provider.err = &RateLimitError{Provider: "recording", RetryAfter: 30 * time.Minute}
+	redisServer.Del(priceRefresherLockKey)
+	assertDelay(t, refresher.run(ctx), 30*time.Minute)
+
+	provider.err = nil
+	redisServer.Del(priceRefresherLockKey)
+	refresher.run(ctx)
+	if status := refresher.Status(); status.ConsecutiveFailures != 0 || status.LastError != "" {
+		t.Errorf("status after recovery = %+v", status)
+	}
+}
+
+func TestPriceRefresherSkipsWhileAnotherInstanceHoldsTheLock(t *testing.T) {
+	provider := &recordingPriceProvider{prices: map[string]string{"BTC": "1000"}}
+	refresher, redisServer := newTestPriceRefresher(t, provider)
+	redisServer.Set(priceRefresherLockKey, "other instance")
+
+	assertDelay(t, refresher.run(context.Background()), time.Minute)
+
+	status := refresher.Status()
+	if !status.Skipped || status.LastRunAt != nil || status.NextRunAt == nil {
+		t.Errorf("status = %+v, want a skipped run", status)
+	}
+	assertCalls(t, provider)
+}
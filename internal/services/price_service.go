@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	quoteCurrency       = "IDR"
+	priceCacheKeyPrefix = "price:"
+
+	// backgroundRefreshInterval spaces out background refreshes of the same
+	// stale coins while the provider keeps failing
+	backgroundRefreshInterval = 5 * time.Second
+
+	// maxPriceAtGap is how far back PriceAt looks for a recorded price;
+	// backfilled history has one price per day
+	maxPriceAtGap = 7 * 24 * time.Hour
+)
+
+var ErrPriceUnavailable = errors.New("price unavailable")
+
+// PriceService answers price lookups for the rest of the application. It
+// sits in front of a PriceProvider and caches every coin per vs currency,
+// first in a short-lived in-process cache and then in Redis. Concurrent
+// misses for the same coins share one provider call, and every fresh IDR
+// quote is recorded in the price history.
+//
+// A cached price is fresh for CACHE_DURATION_SECONDS. After that it is kept
+// as the last known good price and still served, flagged as stale, while a
+// background refresh runs, up to PRICE_MAX_STALENESS_SECONDS. Beyond that
+// the price is fetched synchronously and ErrPriceUnavailable is returned if
+// the provider cannot deliver it.
+type PriceService struct {
+	provider         PriceProvider
+	assets           *AssetRegistry
+	redisClient      *redis.Client
+	priceHistoryRepo repository.PriceHistoryRepository
+
+	freshFor     time.Duration
+	maxStaleness time.Duration
+
+	local   *localPriceCache
+	fetches singleflight.Group
+
+	refreshMu   sync.Mutex
+	refreshedAt map[string]time.Time
+}
+
+func NewPriceService(
+	provider PriceProvider,
+	assets *AssetRegistry,
+	redisClient *redis.Client,
+	priceHistoryRepo repository.PriceHistoryRepository,
+) *PriceService {
+	return &PriceService{
+		provider:         provider,
+		assets:           assets,
+		redisClient:      redisClient,
+		priceHistoryRepo: priceHistoryRepo,
+
+		freshFor:     config.GetCacheDuration(),
+		maxStaleness: config.GetPriceMaxStaleness(),
+
+		local:       newLocalPriceCache(config.GetLocalPriceCacheDuration()),
+		refreshedAt: make(map[string]time.Time),
+	}
+}
+
+// cachedQuote is how a price is stored in Redis
+type cachedQuote struct {
+	Price     decimal.Decimal `json:"price"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+func priceCacheKey(symbol, vs string) string {
+	return priceCacheKeyPrefix + strings.ToLower(vs) + ":" + strings.ToUpper(symbol)
+}
+
+// GetPrices returns the IDR price of every enabled asset. IDR is the quote
+// currency and always worth 1.
+func (s *PriceService) GetPrices() (map[string]decimal.Decimal, error) {
+	quotes, err := s.GetQuotes(s.assets.PricedSymbols(), quoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]decimal.Decimal, len(quotes))
+	for symbol, quote := range quotes {
+		prices[symbol] = quote.Price
+	}
+	return prices, nil
+}
+
+// GetQuotes returns the price of each symbol in the vs currency. Only the
+// coins found in neither cache, or cached for longer than the maximum
+// staleness, are fetched, in a single provider call. Symbols that cannot be
+// priced are left out; an error is returned only when none of them could be
+// priced.
+func (s *PriceService) GetQuotes(symbols []string, vs string) (map[string]models.PriceQuote, error) {
+	ctx := context.Background()
+	vs = strings.ToLower(vs)
+	now := time.Now()
+
+	quotes := make(map[string]models.PriceQuote, len(symbols))
+	var stale []string
+	use := func(symbol string, price decimal.Decimal, fetchedAt time.Time) bool {
+		age := now.Sub(fetchedAt)
+		if age > s.maxStaleness {
+			return false
+		}
+		if age < 0 {
+			age = 0
+		}
+
+		quote := models.PriceQuote{Price: price, FetchedAt: fetchedAt, AgeSeconds: int64(age / time.Second)}
+		if age > s.freshFor {
+			quote.Stale = true
+			stale = append(stale, symbol)
+		}
+		quotes[symbol] = quote
+		return true
+	}
+
+	var missing []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if symbol == strings.ToUpper(vs) {
+			quotes[symbol] = models.PriceQuote{Price: decimal.NewFromInt(1), FetchedAt: now.UTC()}
+			continue
+		}
+		if entry, ok := s.local.get(priceCacheKey(symbol, vs)); ok && use(symbol, entry.price, entry.fetchedAt) {
+			continue
+		}
+		missing = append(missing, symbol)
+	}
+
+	if len(missing) > 0 {
+		cached := s.readCache(ctx, missing, vs)
+		var tooOld []string
+		for _, symbol := range missing {
+			if entry, ok := cached[symbol]; !ok || !use(symbol, entry.Price, entry.FetchedAt) {
+				tooOld = append(tooOld, symbol)
+			}
+		}
+		missing = tooOld
+	}
+
+	if len(stale) > 0 {
+		s.refreshInBackground(stale, vs)
+	}
+	if len(missing) == 0 {
+		return quotes, nil
+	}
+
+	fetched, err := s.fetch(ctx, missing, vs)
+	fetchedAt := time.Now().UTC()
+	for symbol, price := range fetched {
+		quotes[symbol] = models.PriceQuote{Price: price, FetchedAt: fetchedAt}
+	}
+	if err != nil && len(quotes) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrPriceUnavailable, err)
+	}
+
+	return quotes, nil
+}
+
+// Quote returns the IDR price of a single currency with its age
+func (s *PriceService) Quote(currency string) (models.PriceQuote, error) {
+	currency = strings.ToUpper(currency)
+
+	if currency == quoteCurrency {
+		return models.PriceQuote{Price: decimal.NewFromInt(1), FetchedAt: time.Now().UTC()}, nil
+	}
+
+	quotes, err := s.GetQuotes([]string{currency}, quoteCurrency)
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+
+	if quote, ok := quotes[currency]; ok {
+		return quote, nil
+	}
+
+	return models.PriceQuote{}, fmt.Errorf("%w for currency: %s", ErrPriceUnavailable, currency)
+}
+
+// GetPrice returns the IDR price of a single currency
+func (s *PriceService) GetPrice(currency string) (decimal.Decimal, error) {
+	quote, err := s.Quote(currency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return quote.Price, nil
+}
+
+// Refresh fetches every enabled asset from the provider, bypassing the
+// caches, and stores the result. It returns how many assets were priced.
+func (s *PriceService) Refresh(ctx context.Context) (int, error) {
+	symbols := s.assets.PricedSymbols()
+	if len(symbols) == 0 {
+		return 0, nil
+	}
+
+	prices, err := s.fetch(ctx, symbols, strings.ToLower(quoteCurrency))
+	return len(prices), err
+}
+
+// readCache looks the symbols up in Redis and returns the entries found.
+// Redis being unavailable is treated as a miss.
+func (s *PriceService) readCache(ctx context.Context, symbols []string, vs string) map[string]cachedQuote {
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = priceCacheKey(symbol, vs)
+	}
+
+	values, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil
+	}
+
+	found := make(map[string]cachedQuote, len(symbols))
+	for i, symbol := range symbols {
+		value, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		var entry cachedQuote
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			continue
+		}
+		found[symbol] = entry
+		s.local.set(keys[i], entry.Price, entry.FetchedAt)
+	}
+	return found
+}
+
+// fetch asks the provider for the symbols and caches whatever it priced.
+// Callers missing the same set of coins at the same time wait for one call.
+func (s *PriceService) fetch(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	sorted := append([]string(nil), symbols...)
+	sort.Strings(sorted)
+
+	result, err, _ := s.fetches.Do(vs+":"+strings.Join(sorted, ","), func() (interface{}, error) {
+		prices, err := s.provider.GetPrices(ctx, sorted, vs)
+		if len(prices) > 0 {
+			s.store(ctx, prices, vs)
+		}
+		return prices, err
+	})
+
+	prices, _ := result.(map[string]decimal.Decimal)
+	return prices, err
+}
+
+// refreshInBackground fetches stale symbols without making the caller wait.
+// While the provider keeps failing, the same symbols are retried at most
+// once per backgroundRefreshInterval.
+func (s *PriceService) refreshInBackground(symbols []string, vs string) {
+	now := time.Now()
+	var due []string
+
+	s.refreshMu.Lock()
+	for _, symbol := range symbols {
+		key := priceCacheKey(symbol, vs)
+		if now.Sub(s.refreshedAt[key]) < backgroundRefreshInterval {
+			continue
+		}
+		s.refreshedAt[key] = now
+		due = append(due, symbol)
+	}
+	s.refreshMu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	go func() {
+		if _, err := s.fetch(context.Background(), due, vs); err != nil {
+			log.Printf("Background price refresh failed, serving stale prices: %v", err)
+		}
+	}()
+}
+
+func (s *PriceService) store(ctx context.Context, prices map[string]decimal.Decimal, vs string) {
+	// The entry outlives its freshness so it can serve as the last known
+	// good price
+	now := time.Now().UTC()
+
+	pipe := s.redisClient.Pipeline()
+	for symbol, price := range prices {
+		key := priceCacheKey(symbol, vs)
+		data, _ := json.Marshal(cachedQuote{Price: price, FetchedAt: now})
+		pipe.Set(ctx, key, data, s.maxStaleness)
+		s.local.set(key, price, now)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to cache prices: %v", err)
+	}
+
+	if strings.EqualFold(vs, quoteCurrency) {
+		s.recordHistory(prices)
+	}
+}
+
+// History returns the recorded prices of the currencies between from and to,
+// at most one per bucket, oldest first. Each series starts with the last
+// price known before from so that the first bucket can be valued too.
+func (s *PriceService) History(currencies []string, from, to time.Time, bucket time.Duration) (map[string][]models.PriceHistory, error) {
+	series := make(map[string][]models.PriceHistory, len(currencies))
+	if len(currencies) == 0 {
+		return series, nil
+	}
+
+	for _, currency := range currencies {
+		before, err := s.priceHistoryRepo.FindLatestBefore(currency, from)
+		if err != nil {
+			return nil, err
+		}
+		if before != nil {
+			series[currency] = append(series[currency], *before)
+		}
+	}
+
+	prices, err := s.priceHistoryRepo.FindLastPerBucket(currencies, from, to, bucket)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		series[price.Currency] = append(series[price.Currency], price)
+	}
+
+	return series, nil
+}
+
+// PriceAt returns the IDR price of a currency at the given time: the last
+// price recorded at or before it, or the current price for recent times.
+// Valuation code should use it instead of reading the history directly.
+func (s *PriceService) PriceAt(symbol string, at time.Time) (decimal.Decimal, error) {
+	symbol = strings.ToUpper(symbol)
+
+	if symbol == quoteCurrency {
+		return decimal.NewFromInt(1), nil
+	}
+	if time.Since(at) <= s.freshFor {
+		return s.GetPrice(symbol)
+	}
+
+	price, err := s.priceHistoryRepo.FindLatestBefore(symbol, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if price == nil || at.Sub(price.RecordedAt) > maxPriceAtGap {
+		return decimal.Zero, fmt.Errorf("%w: no %s price recorded near %s", ErrPriceUnavailable, symbol, at.UTC().Format(time.RFC3339))
+	}
+	return price.PriceIDR, nil
+}
+
+// PriceHistory returns the recorded prices of one currency between from and
+// to, at most one per interval
+func (s *PriceService) PriceHistory(symbol string, from, to time.Time, interval string) (*models.PriceHistoryResponse, error) {
+	symbol = strings.ToUpper(symbol)
+
+	step, ok := historyIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported interval %q", ErrInvalidHistoryRange, interval)
+	}
+	if now := time.Now().UTC(); to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidHistoryRange)
+	}
+	if count := int((to.Sub(from) + step - 1) / step); count > maxHistoryPoints {
+		return nil, fmt.Errorf("%w: range exceeds %d points, use a larger interval", ErrInvalidHistoryRange, maxHistoryPoints)
+	}
+
+	prices, err := s.priceHistoryRepo.FindLastPerBucket([]string{symbol}, from, to, step)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.PriceHistoryResponse{
+		Symbol:   symbol,
+		From:     from,
+		To:       to,
+		Interval: interval,
+		Points:   make([]models.PricePoint, 0, len(prices)),
+	}
+	for _, price := range prices {
+		response.Points = append(response.Points, models.PricePoint{
+			Timestamp: price.RecordedAt,
+			PriceIDR:  price.PriceIDR,
+			Source:    price.Source,
+		})
+	}
+	return response, nil
+}
+
+// recordHistory stores freshly fetched prices. Failing to record is logged
+// but never fails the price lookup itself.
+func (s *PriceService) recordHistory(prices map[string]decimal.Decimal) {
+	now := time.Now().UTC()
+	rows := make([]models.PriceHistory, 0, len(prices))
+	for currency, price := range prices {
+		rows = append(rows, models.PriceHistory{
+			Currency:   currency,
+			PriceIDR:   price,
+			Source:     s.provider.Name(),
+			RecordedAt: now,
+		})
+	}
+
+	if err := s.priceHistoryRepo.CreateBatch(rows); err != nil {
+		log.Printf("Failed to record price history: %v", err)
+	}
+}
+
+// ClearCache drops every cached price, locally and in Redis
+func (s *PriceService) ClearCache() error {
+	ctx := context.Background()
+	s.local.clear()
+
+	iter := s.redisClient.Scan(ctx, 0, priceCacheKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := s.redisClient.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
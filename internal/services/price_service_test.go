@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/shopspring/decimal"
+)
+
+// recordingPriceProvider prices every symbol it knows and records each call.
+// While gate is set, calls block until it is closed.
+type recordingPriceProvider struct {
+	mu     sync.Mutex
+	prices map[string]string
+	err    error
+	gate   chan struct{}
+	calls  []string // "vs:SYMBOL,SYMBOL" per call
+}
+
+func (p *recordingPriceProvider) Name() string {
+	return "recording"
+}
+
+func (p *recordingPriceProvider) GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, vs+":"+strings.Join(symbols, ","))
+	gate, err := p.gate, p.err
+	p.mu.Unlock()
+
+	if gate != nil {
+		<-gate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]decimal.Decimal)
+	for _, symbol := range symbols {
+		if value, ok := p.prices[symbol]; ok {
+			prices[symbol] = decimal.RequireFromString(value)
+		}
+	}
+	return prices, nil
+}
+
+func (p *recordingPriceProvider) recorded() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+// fakePriceHistoryRepo keeps recorded prices in memory
+type fakePriceHistoryRepo struct {
+	repository.PriceHistoryRepository
+	mu     sync.Mutex
+	prices []models.PriceHistory
+}
+
+func (r *fakePriceHistoryRepo) CreateBatch(prices []models.PriceHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prices = append(r.prices, prices...)
+	return nil
+}
+
+func (r *fakePriceHistoryRepo) FindLatestBefore(currency string, at time.Time) (*models.PriceHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest *models.PriceHistory
+	for i, price := range r.prices {
+		if price.Currency == currency && !price.RecordedAt.After(at) && (latest == nil || price.RecordedAt.After(latest.RecordedAt)) {
+			latest = &r.prices[i]
+		}
+	}
+	return latest, nil
+}
+
+func (r *fakePriceHistoryRepo) FindLastPerBucket(currencies []string, from, to time.Time, bucket time.Duration) ([]models.PriceHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type key struct {
+		currency string
+		bucket   int64
+	}
+	last := map[key]models.PriceHistory{}
+	for _, price := range r.prices {
+		if !containsSymbol(currencies, price.Currency) || price.RecordedAt.Before(from) || !price.RecordedAt.Before(to) {
+			continue
+		}
+		k := key{price.Currency, int64(price.RecordedAt.Sub(from) / bucket)}
+		if current, ok := last[k]; !ok || price.RecordedAt.After(current.RecordedAt) {
+			last[k] = price
+		}
+	}
+
+	result := make([]models.PriceHistory, 0, len(last))
+	for _, price := range last {
+		result = append(result, price)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RecordedAt.Before(result[j].RecordedAt) })
+	return result, nil
+}
+
+func containsSymbol(symbols []string, symbol string) bool {
+	for _, s := range symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestPriceService(t *testing.T, provider PriceProvider) (*PriceService, *miniredis.Miniredis, *fakePriceHistoryRepo) {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{
+		CoinGecko: config.CoinGeckoConfig{CacheDurationSeconds: 60},
+		Price: config.PriceConfig{
+			LocalCacheSeconds:   5,
+			MaxStalenessSeconds: 900,
+		},
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+	redisServer := startTestRedis(t)
+
+	assets := &AssetRegistry{assets: map[string]models.Asset{}}
+	for _, asset := range []models.Asset{testAsset("IDR", 2), testAsset("BTC", 8), testAsset("ETH", 18)} {
+		if asset.Symbol != "IDR" {
+			asset.ProviderID = strings.ToLower(asset.Symbol)
+		}
+		assets.assets[asset.Symbol] = asset
+	}
+
+	history := &fakePriceHistoryRepo{}
+	return NewPriceService(provider, assets, config.RedisClient, history), redisServer, history
+}
+
+func assertCalls(t *testing.T, provider *recordingPriceProvider, want ...string) {
+	t.Helper()
+	if got := provider.recorded(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("provider calls = %v, want %v", got, want)
+	}
+}
+
+func TestPriceServiceFetchesOnlyMissingCoinsPerVsCurrency(t *testing.T) {
+	provider := &recordingPriceProvider{prices: map[string]string{"BTC": "1000", "ETH": "50"}}
+	service, redisServer, _ := newTestPriceService(t, provider)
+
+	if _, err := service.GetQuotes([]string{"BTC"}, "idr"); err != nil {
+		t.Fatal(err)
+	}
+	quotes, err := service.GetQuotes([]string{"BTC", "ETH", "IDR"}, "idr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.GetQuotes([]string{"BTC"}, "usd"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCalls(t, provider, "idr:BTC", "idr:ETH", "usd:BTC")
+	if !quotes["BTC"].Price.Equal(decimal.NewFromInt(1000)) || !quotes["IDR"].Price.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("quotes = %+v", quotes)
+	}
+	for _, key := range []string{"price:idr:BTC", "price:idr:ETH", "price:usd:BTC"} {
+		if !redisServer.Exists(key) {
+			t.Errorf("%s not cached in Redis", key)
+		}
+	}
+}
+
+func TestPriceServiceCollapsesConcurrentMisses(t *testing.T) {
+	provider := &recordingPriceProvider{prices: map[string]string{"BTC": "1000", "ETH": "50"}, gate: make(chan struct{})}
+	service, _, _ := newTestPriceService(t, provider)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			quotes, err := service.GetQuotes([]string{"ETH", "BTC"}, "idr")
+			if err != nil || len(quotes) != 2 {
+				t.Errorf("quotes = %v, err = %v", quotes, err)
+			}
+		}()
+	}
+
+	// Give every caller time to miss both caches and join the pending fetch
+	time.Sleep(100 * time.Millisecond)
+	close(provider.gate)
+	wg.Wait()
+
+	assertCalls(t, provider, "idr:BTC,ETH")
+}
+
+func TestPriceServiceReadsRedisBehindLocalCache(t *testing.T) {
+	provider := &recordingPriceProvider{prices: map[string]string{"BTC": "1000"}}
+	service, redisServer, _ := newTestPriceService(t, provider)
+
+	if _, err := service.GetPrice("BTC"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance starts with an empty local cache and finds the price
+	// in Redis
+	other := NewPriceService(provider, service.assets, config.RedisClient, &fakePriceHistoryRepo{})
+	if price, err := other.GetPrice("BTC"); err != nil || !price.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("price from Redis = %s, %v, want 1000", price, err)
+	}
+
+	// With Redis emptied the local cache still answers
+	redisServer.FlushAll()
+	if price, err := service.GetPrice("BTC"); err != nil || !price.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("price from the local cache = %s, %v, want 1000", price, err)
+	}
+
+	assertCalls(t, provider, "idr:BTC")
+}
+
+// cacheQuote stores a quote in Redis as if it was fetched age ago
+func cacheQuote(t *testing.T, redisServer *miniredis.Miniredis, symbol, price string, age time.Duration) {
+	t.Helper()
+	data, err := json.Marshal(cachedQuote{Price: decimal.RequireFromString(price), FetchedAt: time.Now().UTC().Add(-age)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := redisServer.Set(priceCacheKey(symbol, "idr"), string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPriceServiceServesStalePriceWithinLimit(t *testing.T) {
+	provider := &recordingPriceProvider{err: errors.New("provider down")}
+	service, redisServer, _ := newTestPriceService(t, provider)
+	cacheQuote(t, redisServer, "BTC", "1000", 2*time.Minute)
+
+	quote, err := service.Quote("BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !quote.Price.Equal(decimal.NewFromInt(1000)) || !quote.Stale || quote.AgeSeconds < 120 || quote.AgeSeconds > 125 {
+		t.Errorf("quote = %+v, want the stale price aged about 120s", quote)
+	}
+
+	// The caller did not wait, but a refresh was started in the background
+	deadline := time.Now().Add(time.Second)
+	for len(provider.recorded()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertCalls(t, provider, "idr:BTC")
+}
+
+func TestPriceServiceFreshPriceIsNotStale(t *testing.T) {
+	provider := &recordingPriceProvider{}
+	service, redisServer, _ := newTestPriceService(t, provider)
+	cacheQuote(t, redisServer, "BTC", "1000", 10*time.Second)
+
+	quote, err := service.Quote("BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Stale || !quote.Price.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("quote = %+v, want a fresh 1000", quote)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assertCalls(t, provider)
+}
+
+func TestPriceServiceRefusesPricesBeyondMaxStaleness(t *testing.T) {
+	provider := &recordingPriceProvider{err: errors.New("provider down")}
+	service, redisServer, _ := newTestPriceService(t, provider)
+	cacheQuote(t, redisServer, "BTC", "1000", 20*time.Minute)
+
+	if _, err := service.Quote("BTC"); !errors.Is(err, ErrPriceUnavailable) {
+		t.Errorf("err = %v, want ErrPriceUnavailable", err)
+	}
+	assertCalls(t, provider, "idr:BTC")
+
+	// Once the provider is back the price is fetched synchronously
+	provider.err = nil
+	provider.prices = map[string]string{"BTC": "1100"}
+	quote, err := service.Quote("BTC")
+	if err != nil || quote.Stale || !quote.Price.Equal(decimal.NewFromInt(1100)) {
+		t.Errorf("quote = %+v, %v, want a fresh 1100", quote, err)
+	}
+}
+
+func recordPrice(history *fakePriceHistoryRepo, symbol, price string, at time.Time) {
+	history.prices = append(history.prices, models.PriceHistory{
+		Currency:   symbol,
+		PriceIDR:   decimal.RequireFromString(price),
+		Source:     "coingecko-backfill",
+		RecordedAt: at,
+	})
+}
+
+func TestPriceAtLooksUpRecordedHistory(t *testing.T) {
+	provider := &recordingPriceProvider{prices: map[string]string{"BTC": "1000"}}
+	service, _, history := newTestPriceService(t, provider)
+
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	recordPrice(history, "BTC", "800", now.Add(-30*day))
+	recordPrice(history, "BTC", "900", now.Add(-10*day))
+	recordPrice(history, "BTC", "950", now.Add(-3*day))
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string // empty when no price is available
+	}{
+		{"between records", now.Add(-5 * day), "900"},
+		{"latest record", now.Add(-2 * day), "950"},
+		{"exactly at a record", now.Add(-10 * day), "900"},
+		{"recent time uses the current price", now, "1000"},
+		{"last record too old", now.Add(-20 * day), ""},
+		{"before any record", now.Add(-40 * day), ""},
+	}
+	for _, tt := range tests {
+		price, err := service.PriceAt("btc", tt.at)
+		if tt.want == "" {
+			if !errors.Is(err, ErrPriceUnavailable) {
+				t.Errorf("%s: price = %s, err = %v, want ErrPriceUnavailable", tt.name, price, err)
+			}
+			continue
+		}
+		if err != nil || !price.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("%s: price = %s, err = %v, want %s", tt.name, price, err, tt.want)
+		}
+	}
+
+	if price, err := service.PriceAt("IDR", now.Add(-400*day)); err != nil || !price.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("IDR price = %s, %v, want 1", price, err)
+	}
+}
+
+func TestPriceHistoryReturnsLastPricePerInterval(t *testing.T) {
+	service, _, history := newTestPriceService(t, &recordingPriceProvider{})
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	recordPrice(history, "BTC", "100", from.Add(2*time.Hour))
+	recordPrice(history, "BTC", "110", from.Add(20*time.Hour))
+	recordPrice(history, "BTC", "120", from.Add(50*time.Hour))
+	recordPrice(history, "ETH", "5", from.Add(3*time.Hour))
+
+	response, err := service.PriceHistory("btc", from, from.AddDate(0, 0, 3), "1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Symbol != "BTC" || response.Interval != "1d" || len(response.Points) != 2 {
+		t.Fatalf("response = %+v, want two BTC points", response)
+	}
+	if !response.Points[0].PriceIDR.Equal(decimal.NewFromInt(110)) || !response.Points[1].PriceIDR.Equal(decimal.NewFromInt(120)) {
+		t.Errorf("points = %+v, want 110 and 120", response.Points)
+	}
+	if response.Points[0].Source != "coingecko-backfill" {
+		t.Errorf("source = %q", response.Points[0].Source)
+	}
+}
+
+func TestPriceHistoryRejectsInvalidRanges(t *testing.T) {
+	service, _, _ := newTestPriceService(t, &recordingPriceProvider{})
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		to       time.Time
+		interval string
+	}{
+		{"unsupported interval", from.AddDate(0, 0, 1), "5m"},
+		{"from after to", from.AddDate(0, 0, -1), "1d"},
+		{"too many points", from.AddDate(0, 0, 60), "1h"},
+	}
+	for _, tt := range tests {
+		if _, err := service.PriceHistory("BTC", from, tt.to, tt.interval); !errors.Is(err, ErrInvalidHistoryRange) {
+			t.Errorf("%s: err = %v, want ErrInvalidHistoryRange", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ReconciliationService compares every Wallet.Balance with the balance
+// recomputed from the transactions table to detect wallets whose balance
+// update and transaction record went out of sync
+type ReconciliationService struct {
+	walletRepo      repository.WalletRepository
+	transactionRepo repository.TransactionRepository
+	uow             repository.UnitOfWork
+}
+
+func NewReconciliationService(
+	walletRepo repository.WalletRepository,
+	transactionRepo repository.TransactionRepository,
+	uow repository.UnitOfWork,
+) *ReconciliationService {
+	return &ReconciliationService{
+		walletRepo:      walletRepo,
+		transactionRepo: transactionRepo,
+		uow:             uow,
+	}
+}
+
+// Run reconciles all wallets. When autoFreeze is set, every drifted wallet is
+// frozen so no further money can move through it until an admin unfreezes it.
+func (s *ReconciliationService) Run(autoFreeze bool) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		StartedAt:  time.Now().UTC(),
+		AutoFreeze: autoFreeze,
+		Drifts:     []models.WalletDrift{},
+	}
+
+	// Both reads come from one snapshot; otherwise a deposit committed
+	// between them would look like drift
+	var (
+		computed []models.ComputedBalance
+		wallets  []models.Wallet
+	)
+	err := s.uow.Snapshot(func(repos repository.Repositories) error {
+		var err error
+		if computed, err = repos.Transactions.SumBalances(); err != nil {
+			return err
+		}
+		wallets, err = repos.Wallets.FindAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type walletKey struct {
+		userID   uuid.UUID
+		currency string
+	}
+	expected := make(map[walletKey]decimal.Decimal, len(computed))
+	for _, b := range computed {
+		expected[walletKey{b.UserID, b.Currency}] = b.Balance
+	}
+
+	for _, wallet := range wallets {
+		report.WalletsChecked++
+
+		balance := expected[walletKey{wallet.UserID, wallet.Currency}]
+		if wallet.Balance.Equal(balance) {
+			continue
+		}
+
+		drift := models.WalletDrift{
+			WalletID:        wallet.ID,
+			UserID:          wallet.UserID,
+			Currency:        wallet.Currency,
+			WalletBalance:   wallet.Balance,
+			ComputedBalance: balance,
+			Drift:           wallet.Balance.Sub(balance),
+			Frozen:          wallet.Frozen,
+		}
+
+		if autoFreeze && !wallet.Frozen {
+			drifted, err := s.freezeIfDrifted(wallet)
+			if err != nil {
+				return nil, err
+			}
+			if !drifted {
+				continue
+			}
+			drift.Frozen = true
+		}
+
+		report.Drifts = append(report.Drifts, drift)
+	}
+
+	report.DriftCount = len(report.Drifts)
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// freezeIfDrifted checks the wallet again under its row lock and freezes it
+// only if the drift is still there; it reports whether it was. Money
+// movements lock the wallet before writing, so the locked balance and its
+// transactions are consistent.
+func (s *ReconciliationService) freezeIfDrifted(wallet models.Wallet) (bool, error) {
+	drifted := false
+	err := s.uow.Do(func(repos repository.Repositories) error {
+		locked, err := repos.Wallets.FindByUserIDAndCurrencyForUpdate(wallet.UserID, wallet.Currency)
+		if err != nil || locked == nil {
+			return err
+		}
+
+		balance, err := repos.Transactions.SumUserBalance(locked.UserID, locked.Currency)
+		if err != nil {
+			return err
+		}
+		if locked.Balance.Equal(balance) {
+			log.Printf("Reconciliation: wallet %s no longer drifts, not freezing", locked.ID)
+			return nil
+		}
+
+		drifted = true
+		if locked.Frozen {
+			return nil
+		}
+		return repos.Wallets.SetFrozen(locked.ID, true)
+	})
+	return drifted, err
+}
+
+// SetWalletFrozen freezes or unfreezes a wallet manually. The wallet is
+// locked like for a money movement, so one already in flight finishes
+// before the flag changes and none starts while it is being changed.
+func (s *ReconciliationService) SetWalletFrozen(walletID uuid.UUID, frozen bool) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	err := s.uow.Do(func(repos repository.Repositories) error {
+		locked, err := repos.Wallets.FindByIDForUpdate(walletID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Wallets.SetFrozen(locked.ID, frozen); err != nil {
+			return err
+		}
+
+		locked.Frozen = frozen
+		wallet = locked
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// StartScheduler runs the reconciliation every interval until ctx is done and
+// logs the report as JSON whenever drift is found
+func (s *ReconciliationService) StartScheduler(ctx context.Context, interval time.Duration, autoFreeze bool) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := s.Run(autoFreeze)
+				if err != nil {
+					log.Printf("Reconciliation failed: %v", err)
+					continue
+				}
+				if report.DriftCount == 0 {
+					continue
+				}
+
+				data, _ := json.Marshal(report)
+				log.Printf("Reconciliation found %d drifted wallets: %s", report.DriftCount, data)
+			}
+		}
+	}()
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// fakeWalletRepo keeps wallets in memory and records which ones were locked.
+// onLock runs when a wallet is locked, standing in for whatever another
+// transaction committed before the lock was granted.
+type fakeWalletRepo struct {
+	repository.WalletRepository
+	wallets []models.Wallet
+	locked  []uuid.UUID
+	onLock  func()
+}
+
+func (r *fakeWalletRepo) FindAll() ([]models.Wallet, error) {
+	return append([]models.Wallet(nil), r.wallets...), nil
+}
+
+func (r *fakeWalletRepo) lock(match func(models.Wallet) bool) *models.Wallet {
+	for i := range r.wallets {
+		if match(r.wallets[i]) {
+			r.locked = append(r.locked, r.wallets[i].ID)
+			if r.onLock != nil {
+				r.onLock()
+			}
+			wallet := r.wallets[i]
+			return &wallet
+		}
+	}
+	return nil
+}
+
+func (r *fakeWalletRepo) FindByUserIDAndCurrencyForUpdate(userID uuid.UUID, currency string) (*models.Wallet, error) {
+	return r.lock(func(w models.Wallet) bool { return w.UserID == userID && w.Currency == currency }), nil
+}
+
+func (r *fakeWalletRepo) FindByIDForUpdate(id uuid.UUID) (*models.Wallet, error) {
+	if wallet := r.lock(func(w models.Wallet) bool { return w.ID == id }); wallet != nil {
+		return wallet, nil
+	}
+	return nil, errors.New("wallet not found")
+}
+
+func (r *fakeWalletRepo) SetFrozen(walletID uuid.UUID, frozen bool) error {
+	for i := range r.wallets {
+		if r.wallets[i].ID == walletID {
+			r.wallets[i].Frozen = frozen
+		}
+	}
+	return nil
+}
+
+func (r *fakeWalletRepo) frozen(id uuid.UUID) bool {
+	for _, w := range r.wallets {
+		if w.ID == id {
+			return w.Frozen
+		}
+	}
+	return false
+}
+
+// fakeBalanceRepo answers the transaction sums from a fixed map
+type fakeBalanceRepo struct {
+	repository.TransactionRepository
+	balances map[uuid.UUID]decimal.Decimal
+}
+
+func (r *fakeBalanceRepo) SumBalances() ([]models.ComputedBalance, error) {
+	var result []models.ComputedBalance
+	for userID, balance := range r.balances {
+		result = append(result, models.ComputedBalance{UserID: userID, Currency: "BTC", Balance: balance})
+	}
+	return result, nil
+}
+
+func (r *fakeBalanceRepo) SumUserBalance(userID uuid.UUID, currency string) (decimal.Decimal, error) {
+	return r.balances[userID], nil
+}
+
+func newTestReconciliationService(balances ...string) (*ReconciliationService, *fakeWalletRepo, *fakeBalanceRepo) {
+	wallets := &fakeWalletRepo{}
+	sums := &fakeBalanceRepo{balances: map[uuid.UUID]decimal.Decimal{}}
+	for _, balance := range balances {
+		wallet := models.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: "BTC", Balance: decimal.RequireFromString(balance)}
+		wallets.wallets = append(wallets.wallets, wallet)
+		sums.balances[wallet.UserID] = wallet.Balance
+	}
+
+	uow := fakeUnitOfWork{repos: repository.Repositories{Wallets: wallets, Transactions: sums}}
+	return NewReconciliationService(wallets, sums, uow), wallets, sums
+}
+
+func TestReconciliationReportsDrift(t *testing.T) {
+	service, wallets, sums := newTestReconciliationService("1.5", "2")
+	drifted := wallets.wallets[1]
+	sums.balances[drifted.UserID] = decimal.RequireFromString("1.75")
+
+	report, err := service.Run(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.WalletsChecked != 2 || report.DriftCount != 1 {
+		t.Fatalf("checked %d wallets, %d drifts, want 2 and 1", report.WalletsChecked, report.DriftCount)
+	}
+	drift := report.Drifts[0]
+	if drift.WalletID != drifted.ID || !drift.Drift.Equal(decimal.RequireFromString("0.25")) {
+		t.Errorf("drift = %+v, want wallet %s drifting by 0.25", drift, drifted.ID)
+	}
+	if drift.Frozen || wallets.frozen(drifted.ID) || len(wallets.locked) != 0 {
+		t.Errorf("wallet was locked or frozen without autoFreeze")
+	}
+}
+
+func TestReconciliationFreezesOnlyWalletsStillDriftingUnderLock(t *testing.T) {
+	service, wallets, sums := newTestReconciliationService("1", "2")
+	stillDrifting, resolved := wallets.wallets[0], wallets.wallets[1]
+	sums.balances[stillDrifting.UserID] = decimal.Zero
+	sums.balances[resolved.UserID] = decimal.Zero
+
+	// The second wallet's missing transaction commits while the snapshot is
+	// being compared; by the time the wallet is locked it no longer drifts
+	wallets.onLock = func() {
+		sums.balances[resolved.UserID] = resolved.Balance
+	}
+
+	report, err := service.Run(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(wallets.locked) != 2 {
+		t.Errorf("locked %d wallets, want both re-checked under lock", len(wallets.locked))
+	}
+	if !wallets.frozen(stillDrifting.ID) || wallets.frozen(resolved.ID) {
+		t.Errorf("frozen = %v and %v, want only the wallet still drifting frozen",
+			wallets.frozen(stillDrifting.ID), wallets.frozen(resolved.ID))
+	}
+	if report.DriftCount != 1 || report.Drifts[0].WalletID != stillDrifting.ID || !report.Drifts[0].Frozen {
+		t.Errorf("drifts = %+v, want only the frozen wallet %s", report.Drifts, stillDrifting.ID)
+	}
+}
+
+func TestSetWalletFrozenLocksTheWallet(t *testing.T) {
+	service, wallets, _ := newTestReconciliationService("1")
+	id := wallets.wallets[0].ID
+
+	wallet, err := service.SetWalletFrozen(id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.Frozen || !wallets.frozen(id) {
+		t.Errorf("wallet not frozen")
+	}
+	if len(wallets.locked) != 1 || wallets.locked[0] != id {
+		t.Errorf("locked = %v, want the wallet locked before freezing", wallets.locked)
+	}
+
+	if _, err := service.SetWalletFrozen(id, false); err != nil || wallets.frozen(id) {
+		t.Errorf("unfreeze: err = %v, frozen = %v", err, wallets.frozen(id))
+	}
+	if _, err := service.SetWalletFrozen(uuid.New(), true); err == nil {
+		t.Error("freezing an unknown wallet succeeded")
+	}
+}
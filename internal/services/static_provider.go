@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// StaticProvider serves prices from a JSON file, for local development, tests
+// or as a last-resort fallback. The file maps vs currency to symbol prices:
+//
+//	{"idr": {"BTC": "950000000", "ETH": "52000000", "USDT": "15500"}}
+type StaticProvider struct {
+	prices map[string]map[string]decimal.Decimal
+}
+
+func NewStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static price file: %w", err)
+	}
+
+	var raw map[string]map[string]decimal.Decimal
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse static price file: %w", err)
+	}
+
+	prices := make(map[string]map[string]decimal.Decimal, len(raw))
+	for vs, quotes := range raw {
+		normalized := make(map[string]decimal.Decimal, len(quotes))
+		for symbol, price := range quotes {
+			normalized[strings.ToUpper(symbol)] = price
+		}
+		prices[strings.ToLower(vs)] = normalized
+	}
+
+	return &StaticProvider{prices: prices}, nil
+}
+
+func (p *StaticProvider) Name() string {
+	return "static"
+}
+
+func (p *StaticProvider) GetPrices(ctx context.Context, symbols []string, vs string) (map[string]decimal.Decimal, error) {
+	quotes := p.prices[strings.ToLower(vs)]
+
+	prices := make(map[string]decimal.Decimal, len(symbols))
+	for _, symbol := range symbols {
+		if price, ok := quotes[symbol]; ok {
+			prices[symbol] = price
+		}
+	}
+
+	return prices, nil
+}
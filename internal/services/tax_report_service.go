@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// taxLocation is Western Indonesia Time; the tax year follows local dates
+var taxLocation = time.FixedZone("WIB", 7*60*60)
+
+// TaxReportService builds the annual Indonesian crypto tax report. Every
+// withdrawal and outgoing transfer is a taxable disposal: PPh final and PPN
+// are charged on its IDR value (amount x price_at) at the configured rates.
+// The gain against the cost basis is reported for reference only, since
+// both taxes are levied on the gross value.
+type TaxReportService struct {
+	transactionRepo repository.TransactionRepository
+
+	pphFinalPercent decimal.Decimal
+	ppnPercent      decimal.Decimal
+}
+
+func NewTaxReportService(transactionRepo repository.TransactionRepository) *TaxReportService {
+	return &TaxReportService{
+		transactionRepo: transactionRepo,
+
+		pphFinalPercent: config.AppConfig.Tax.PPhFinalPercent,
+		ppnPercent:      config.AppConfig.Tax.PPNPercent,
+	}
+}
+
+// Generate builds the report for the given calendar year
+func (s *TaxReportService) Generate(userID uuid.UUID, year int, method models.CostMethod) (*models.TaxReport, error) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, taxLocation)
+	end := start.AddDate(1, 0, 0)
+
+	// Earlier years are needed to know the cost basis of what is disposed of
+	transactions, err := s.transactionRepo.FindByUserIDUntil(userID, end)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.TaxReport{
+		UserID:          userID,
+		Year:            year,
+		Timezone:        "Asia/Jakarta",
+		CostMethod:      method,
+		PPhFinalPercent: s.pphFinalPercent,
+		PPNPercent:      s.ppnPercent,
+		Disposals:       []models.TaxDisposal{},
+		Total:           newTaxSummary("total"),
+		GeneratedAt:     time.Now().UTC(),
+	}
+
+	hundred := decimal.NewFromInt(100)
+	trackers := map[string]*costTracker{}
+	monthly := map[string]*models.TaxSummary{}
+	perAsset := map[string]*models.TaxSummary{}
+
+	for _, tx := range transactions {
+		if tx.Currency == quoteCurrency || !tx.CreatedAt.Before(end) {
+			continue
+		}
+
+		tracker, ok := trackers[tx.Currency]
+		if !ok {
+			tracker = newCostTracker(method)
+			trackers[tx.Currency] = tracker
+		}
+
+		if tx.SignedAmount().IsPositive() {
+			tracker.acquire(tx.Amount, tx.PriceAt)
+			continue
+		}
+
+		isFee := tx.Type == models.TransactionTypeFee
+		cost := tracker.dispose(tx.Amount, tx.PriceAt, isFee)
+		if isFee || tx.CreatedAt.Before(start) {
+			continue
+		}
+
+		value := tx.Amount.Mul(tx.PriceAt).Round(2)
+		disposal := models.TaxDisposal{
+			TransactionID: tx.ID,
+			Date:          tx.CreatedAt.In(taxLocation),
+			Type:          tx.Type,
+			Currency:      tx.Currency,
+			Amount:        tx.Amount,
+			PriceIDR:      tx.PriceAt,
+			ValueIDR:      value,
+			CostBasisIDR:  cost.Round(2),
+			GainIDR:       value.Sub(cost).Round(2),
+			PPhIDR:        value.Mul(s.pphFinalPercent).Div(hundred).Round(2),
+			PPNIDR:        value.Mul(s.ppnPercent).Div(hundred).Round(2),
+		}
+		if !tx.PriceAt.IsPositive() {
+			disposal.PriceMissing = true
+			disposal.GainIDR = decimal.Zero
+			report.MissingPrices++
+		}
+
+		report.Disposals = append(report.Disposals, disposal)
+		report.Total.Add(disposal)
+		addToSummary(monthly, disposal.Date.Format("2006-01"), disposal)
+		addToSummary(perAsset, disposal.Currency, disposal)
+	}
+
+	report.Monthly = sortedSummaries(monthly)
+	report.PerAsset = sortedSummaries(perAsset)
+	return report, nil
+}
+
+func newTaxSummary(key string) models.TaxSummary {
+	return models.TaxSummary{
+		Key:      key,
+		ValueIDR: decimal.Zero,
+		GainIDR:  decimal.Zero,
+		PPhIDR:   decimal.Zero,
+		PPNIDR:   decimal.Zero,
+		TaxIDR:   decimal.Zero,
+	}
+}
+
+func addToSummary(summaries map[string]*models.TaxSummary, key string, disposal models.TaxDisposal) {
+	summary, ok := summaries[key]
+	if !ok {
+		s := newTaxSummary(key)
+		summary = &s
+		summaries[key] = summary
+	}
+	summary.Add(disposal)
+}
+
+func sortedSummaries(summaries map[string]*models.TaxSummary) []models.TaxSummary {
+	result := make([]models.TaxSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeTransactionRepo serves a fixed transaction history. Only the methods
+// used by the code under test are implemented.
+type fakeTransactionRepo struct {
+	repository.TransactionRepository
+	transactions []models.Transaction
+}
+
+func (r *fakeTransactionRepo) FindByUserIDUntil(userID uuid.UUID, until time.Time) ([]models.Transaction, error) {
+	var result []models.Transaction
+	for _, tx := range r.transactions {
+		if tx.UserID == userID && !tx.CreatedAt.After(until) {
+			result = append(result, tx)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func newTestTaxReportService(transactions []models.Transaction) *TaxReportService {
+	return &TaxReportService{
+		transactionRepo: &fakeTransactionRepo{transactions: transactions},
+		pphFinalPercent: dec("0.1"),
+		ppnPercent:      dec("0.11"),
+	}
+}
+
+func taxTx(userID uuid.UUID, txType models.TransactionType, direction models.TransactionDirection, amount, price, at string) models.Transaction {
+	createdAt, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		panic(err)
+	}
+	return models.Transaction{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      txType,
+		Direction: direction,
+		Currency:  "BTC",
+		Amount:    dec(amount),
+		PriceAt:   dec(price),
+		CreatedAt: createdAt,
+	}
+}
+
+func TestTaxReportChargesGrossValue(t *testing.T) {
+	userID := uuid.New()
+	service := newTestTaxReportService([]models.Transaction{
+		taxTx(userID, models.TransactionTypeDeposit, models.TransactionDirectionCredit, "1", "800000000", "2025-01-10T03:00:00Z"),
+		taxTx(userID, models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "0.5", "1000000000", "2025-03-10T03:00:00Z"),
+		taxTx(userID, models.TransactionTypeFee, models.TransactionDirectionDebit, "0.001", "1000000000", "2025-03-10T03:00:00Z"),
+	})
+
+	report, err := service.Generate(userID, 2025, models.CostMethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Disposals) != 1 {
+		t.Fatalf("got %d disposals, want 1 (fees are not disposals)", len(report.Disposals))
+	}
+	d := report.Disposals[0]
+	for _, check := range []struct {
+		name      string
+		got, want string
+	}{
+		{"value", d.ValueIDR.String(), "500000000"},
+		{"cost basis", d.CostBasisIDR.String(), "400000000"},
+		{"gain", d.GainIDR.String(), "100000000"},
+		{"pph", d.PPhIDR.String(), "500000"}, // 0.1% of the gross value, not of the gain
+		{"ppn", d.PPNIDR.String(), "550000"}, // 0.11% of the gross value
+	} {
+		if !dec(check.got).Equal(dec(check.want)) {
+			t.Errorf("%s = %s, want %s", check.name, check.got, check.want)
+		}
+	}
+
+	if !report.Total.TaxIDR.Equal(dec("1050000")) {
+		t.Errorf("total tax = %s, want 1050000", report.Total.TaxIDR)
+	}
+}
+
+func TestTaxReportRoundsToRupiahCents(t *testing.T) {
+	userID := uuid.New()
+	service := newTestTaxReportService([]models.Transaction{
+		taxTx(userID, models.TransactionTypeDeposit, models.TransactionDirectionCredit, "1", "900000000", "2025-01-10T03:00:00Z"),
+		taxTx(userID, models.TransactionTypeTransfer, models.TransactionDirectionDebit, "0.00012345", "987654321", "2025-02-10T03:00:00Z"),
+	})
+
+	report, err := service.Generate(userID, 2025, models.CostMethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Disposals) != 1 {
+		t.Fatalf("got %d disposals, want 1", len(report.Disposals))
+	}
+
+	// 0.00012345 x 987654321 = 121925.92592745
+	d := report.Disposals[0]
+	if !d.ValueIDR.Equal(dec("121925.93")) {
+		t.Errorf("value = %s, want 121925.93", d.ValueIDR)
+	}
+	if !d.PPhIDR.Equal(dec("121.93")) {
+		t.Errorf("pph = %s, want 121.93", d.PPhIDR)
+	}
+	if !d.PPNIDR.Equal(dec("134.12")) {
+		t.Errorf("ppn = %s, want 134.12", d.PPNIDR)
+	}
+	if d.Type != models.TransactionTypeTransfer {
+		t.Errorf("type = %s, want transfer", d.Type)
+	}
+}
+
+func TestTaxReportYearBoundariesFollowWIB(t *testing.T) {
+	userID := uuid.New()
+	service := newTestTaxReportService([]models.Transaction{
+		taxTx(userID, models.TransactionTypeDeposit, models.TransactionDirectionCredit, "4", "100", "2024-06-01T00:00:00Z"),
+		// 2024-12-31 23:59:59 WIB: previous year, only reduces the lots
+		taxTx(userID, models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "1", "1000", "2024-12-31T16:59:59Z"),
+		// 2025-01-01 00:30 WIB although still 2024 in UTC
+		taxTx(userID, models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "1", "1000", "2024-12-31T17:30:00Z"),
+		// 2025-12-31 23:59:59 WIB
+		taxTx(userID, models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "1", "1000", "2025-12-31T16:59:59Z"),
+		// 2026-01-01 00:00 WIB: next year
+		taxTx(userID, models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "1", "1000", "2025-12-31T17:00:00Z"),
+	})
+
+	report, err := service.Generate(userID, 2025, models.CostMethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Disposals) != 2 {
+		t.Fatalf("got %d disposals, want 2", len(report.Disposals))
+	}
+	if got := report.Disposals[0].Date.Format("2006-01-02 15:04"); got != "2025-01-01 00:30" {
+		t.Errorf("first disposal date = %s, want 2025-01-01 00:30 WIB", got)
+	}
+
+	var months []string
+	for _, summary := range report.Monthly {
+		months = append(months, summary.Key)
+	}
+	if len(months) != 2 || months[0] != "2025-01" || months[1] != "2025-12" {
+		t.Errorf("months = %v, want [2025-01 2025-12]", months)
+	}
+
+	// The pre-year disposal consumed one unit of the 100 lot, so the cost
+	// basis of both disposals is still 100
+	if !report.Total.ValueIDR.Equal(dec("2000")) || !report.Total.GainIDR.Equal(dec("1800")) {
+		t.Errorf("total value/gain = %s/%s, want 2000/1800", report.Total.ValueIDR, report.Total.GainIDR)
+	}
+	if !report.Total.PPhIDR.Equal(dec("2")) || !report.Total.PPNIDR.Equal(dec("2.2")) {
+		t.Errorf("total pph/ppn = %s/%s, want 2/2.2", report.Total.PPhIDR, report.Total.PPNIDR)
+	}
+}
+
+func TestTaxReportFlagsMissingPrice(t *testing.T) {
+	userID := uuid.New()
+	service := newTestTaxReportService([]models.Transaction{
+		taxTx(userID, models.TransactionTypeDeposit, models.TransactionDirectionCredit, "1", "100", "2025-01-10T03:00:00Z"),
+		taxTx(userID, models.TransactionTypeWithdraw, models.TransactionDirectionDebit, "1", "0", "2025-02-10T03:00:00Z"),
+	})
+
+	report, err := service.Generate(userID, 2025, models.CostMethodFIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.MissingPrices != 1 || !report.Disposals[0].PriceMissing {
+		t.Errorf("missing prices = %d, want 1 flagged disposal", report.MissingPrices)
+	}
+	if !report.Disposals[0].GainIDR.IsZero() || !report.Total.TaxIDR.IsZero() {
+		t.Errorf("a disposal without price must not report gain or tax")
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"crypto-wallet-service/config"
+	"os"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the PostgreSQL database named by TEST_DB_NAME,
+// using the usual DB_* variables for everything else, and migrates it.
+// Tests that need a database are skipped when TEST_DB_NAME is not set.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	name := os.Getenv("TEST_DB_NAME")
+	if name == "" {
+		t.Skip("TEST_DB_NAME not set, skipping database test")
+	}
+
+	cfg := config.LoadConfig()
+	cfg.Database.DBName = name
+
+	db, err := config.InitDatabase(cfg.Database)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
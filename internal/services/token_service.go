@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// TokenService issues access tokens together with rotating refresh tokens.
+// Every login starts a token family; refreshing revokes the presented token
+// and issues its successor in the same family. Presenting a token that was
+// already rotated means it leaked, so the whole family is revoked.
+type TokenService struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+}
+
+func NewTokenService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository) *TokenService {
+	return &TokenService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// IssueTokens starts a new session for the user
+func (s *TokenService) IssueTokens(user *models.User) (*models.TokenPair, error) {
+	return s.issue(user, uuid.New())
+}
+
+// Refresh rotates a refresh token and returns a new token pair
+func (s *TokenService) Refresh(rawToken string) (*models.TokenPair, error) {
+	current, err := s.refreshTokenRepo.FindByHash(hashToken(rawToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if current.RevokedAt != nil {
+		s.revokeFamily(current.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(current.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	pair, next, err := s.newPair(user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two concurrent refreshes with the same token: only one may win, the
+	// other is treated as reuse. A failed rotation leaves the current token
+	// valid so the client can retry.
+	rotated, err := s.refreshTokenRepo.Rotate(current.ID, next)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		s.revokeFamily(current.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	return pair, nil
+}
+
+// Logout ends the session of the given access token: the refresh token
+// family is revoked and all of its access tokens are denied
+func (s *TokenService) Logout(ctx context.Context, claims *middleware.JWTClaims) error {
+	if err := s.refreshTokenRepo.RevokeFamily(claims.SessionID); err != nil {
+		return err
+	}
+	if err := middleware.RevokeSession(ctx, claims.SessionID); err != nil {
+		return err
+	}
+	return middleware.RevokeAccessToken(ctx, claims)
+}
+
+// RevokeAllSessions logs the user out everywhere
+func (s *TokenService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	familyIDs, err := s.refreshTokenRepo.RevokeAllForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, familyID := range familyIDs {
+		if err := middleware.RevokeSession(ctx, familyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TokenService) issue(user *models.User, familyID uuid.UUID) (*models.TokenPair, error) {
+	pair, refreshToken, err := s.newPair(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshTokenRepo.Create(refreshToken); err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func (s *TokenService) newPair(user *models.User, familyID uuid.UUID) (*models.TokenPair, *models.RefreshToken, error) {
+	accessToken, err := middleware.GenerateToken(user.ID, user.Email, familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawRefresh, err := randomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(rawRefresh),
+		ExpiresAt: time.Now().UTC().Add(config.GetRefreshTokenTTL()),
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawRefresh,
+		ExpiresIn:    int64(config.GetAccessTokenTTL().Seconds()),
+	}, refreshToken, nil
+}
+
+func (s *TokenService) revokeFamily(familyID uuid.UUID) {
+	log.Printf("Refresh token reuse detected, revoking session %s", familyID)
+	if err := s.refreshTokenRepo.RevokeFamily(familyID); err != nil {
+		log.Printf("Failed to revoke refresh token family %s: %v", familyID, err)
+	}
+	if err := middleware.RevokeSession(context.Background(), familyID); err != nil {
+		log.Printf("Failed to deny session %s: %v", familyID, err)
+	}
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
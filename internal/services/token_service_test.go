@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"crypto-wallet-service/config"
+	"crypto-wallet-service/internal/middleware"
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeRefreshTokenRepo keeps refresh tokens in memory
+type fakeRefreshTokenRepo struct {
+	repository.RefreshTokenRepository
+	tokens []models.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepo) Create(token *models.RefreshToken) error {
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) FindByHash(hash string) (*models.RefreshToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, errors.New("refresh token not found")
+}
+
+func (r *fakeRefreshTokenRepo) Rotate(currentID uuid.UUID, next *models.RefreshToken) (bool, error) {
+	for i := range r.tokens {
+		if r.tokens[i].ID == currentID && r.tokens[i].RevokedAt == nil {
+			now := time.Now().UTC()
+			r.tokens[i].RevokedAt = &now
+			r.tokens[i].ReplacedByID = &next.ID
+			r.tokens = append(r.tokens, *next)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRefreshTokenRepo) revokeWhere(match func(models.RefreshToken) bool) []uuid.UUID {
+	var familyIDs []uuid.UUID
+	now := time.Now().UTC()
+	for i := range r.tokens {
+		if r.tokens[i].RevokedAt == nil && match(r.tokens[i]) {
+			r.tokens[i].RevokedAt = &now
+			familyIDs = append(familyIDs, r.tokens[i].FamilyID)
+		}
+	}
+	return familyIDs
+}
+
+func (r *fakeRefreshTokenRepo) RevokeFamily(familyID uuid.UUID) error {
+	r.revokeWhere(func(t models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeAllForUser(userID uuid.UUID) ([]uuid.UUID, error) {
+	return r.revokeWhere(func(t models.RefreshToken) bool { return t.UserID == userID }), nil
+}
+
+func (r *fakeRefreshTokenRepo) active(familyID uuid.UUID) int {
+	count := 0
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func newTestTokenService(t *testing.T) (*TokenService, *fakeRefreshTokenRepo, models.User) {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{
+		Secret:           "test-secret",
+		AccessTTLMinutes: 15,
+		RefreshTTLHours:  1,
+	}}
+	t.Cleanup(func() { config.AppConfig = previous })
+	startTestRedis(t)
+
+	user := models.User{ID: uuid.New(), Email: "user@example.com"}
+	users := &fakeUserRepo{users: map[uuid.UUID]models.User{user.ID: user}}
+	tokens := &fakeRefreshTokenRepo{}
+	return NewTokenService(users, tokens), tokens, user
+}
+
+func accessClaims(t *testing.T, pair *models.TokenPair) *middleware.JWTClaims {
+	t.Helper()
+	claims, err := middleware.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func assertAccessRevoked(t *testing.T, pair *models.TokenPair, want bool) {
+	t.Helper()
+	revoked, err := middleware.IsTokenRevoked(context.Background(), accessClaims(t, pair))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != want {
+		t.Errorf("access token revoked = %v, want %v", revoked, want)
+	}
+}
+
+func TestRefreshRotatesWithinTheFamily(t *testing.T) {
+	service, tokens, user := newTestTokenService(t)
+
+	first, err := service.IssueTokens(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh returned the same refresh token")
+	}
+	family := accessClaims(t, first).SessionID
+	if got := accessClaims(t, second).SessionID; got != family {
+		t.Errorf("rotated session = %s, want the original family %s", got, family)
+	}
+	if tokens.active(family) != 1 || len(tokens.tokens) != 2 {
+		t.Errorf("%d tokens, %d active, want the old one revoked and one active", len(tokens.tokens), tokens.active(family))
+	}
+	assertAccessRevoked(t, second, false)
+}
+
+func TestRefreshTokenReuseRevokesTheFamily(t *testing.T) {
+	service, tokens, user := newTestTokenService(t)
+
+	first, err := service.IssueTokens(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.IssueTokens(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the rotated token means it leaked
+	if _, err := service.Refresh(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := service.Refresh(second.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("successor err = %v, want ErrRefreshTokenReused", err)
+	}
+	if n := tokens.active(accessClaims(t, first).SessionID); n != 0 {
+		t.Errorf("%d tokens still active in the reused family", n)
+	}
+	assertAccessRevoked(t, second, true)
+
+	// Other sessions of the user are untouched
+	assertAccessRevoked(t, other, false)
+	if _, err := service.Refresh(other.RefreshToken); err != nil {
+		t.Errorf("other session refresh: %v", err)
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpiredTokens(t *testing.T) {
+	service, tokens, user := newTestTokenService(t)
+
+	if _, err := service.Refresh("unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	pair, err := service.IssueTokens(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+	if _, err := service.Refresh(pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expired token err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutDeniesTheSession(t *testing.T) {
+	service, tokens, user := newTestTokenService(t)
+
+	pair, err := service.IssueTokens(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := accessClaims(t, pair)
+
+	if err := service.Logout(context.Background(), claims); err != nil {
+		t.Fatal(err)
+	}
+	assertAccessRevoked(t, pair, true)
+	if tokens.active(claims.SessionID) != 0 {
+		t.Error("refresh token still active after logout")
+	}
+	if _, err := service.Refresh(pair.RefreshToken); err == nil {
+		t.Error("refresh after logout succeeded")
+	}
+}
+
+func TestRevokeAllSessionsEndsEverySession(t *testing.T) {
+	service, tokens, user := newTestTokenService(t)
+
+	var pairs []*models.TokenPair
+	for i := 0; i < 3; i++ {
+		pair, err := service.IssueTokens(&user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pairs = append(pairs, pair)
+	}
+	stranger := models.User{ID: uuid.New(), Email: "other@example.com"}
+	service.userRepo.(*fakeUserRepo).users[stranger.ID] = stranger
+	strangerPair, err := service.IssueTokens(&stranger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.RevokeAllSessions(context.Background(), user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range pairs {
+		assertAccessRevoked(t, pair, true)
+		if tokens.active(accessClaims(t, pair).SessionID) != 0 {
+			t.Error("refresh token still active after revoke-all")
+		}
+	}
+	assertAccessRevoked(t, strangerPair, false)
+	if _, err := service.Refresh(strangerPair.RefreshToken); err != nil {
+		t.Errorf("another user's session was revoked: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes; with 6 digits the code is their last six
+	tests := []struct {
+		unix int64
+		rfc  string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := totpCode(rfc6238Secret, tt.unix/int64(totpPeriod.Seconds()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.rfc[len(tt.rfc)-totpDigits:]; code != want {
+			t.Errorf("T=%d: code = %s, want %s", tt.unix, code, want)
+		}
+	}
+}
+
+func TestTOTPCodeAcceptsLowercaseSecret(t *testing.T) {
+	code, err := totpCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1)
+	if err != nil || code != "287082" {
+		t.Errorf("code = %s, %v, want 287082", code, err)
+	}
+}
+
+func TestMatchTOTPSkewWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / int64(totpPeriod.Seconds())
+
+	tests := []struct {
+		name    string
+		offset  int64
+		matched bool
+	}{
+		{"current step", 0, true},
+		{"one step behind", -1, true},
+		{"one step ahead", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+
+	for _, tt := range tests {
+		code, err := totpCode(rfc6238Secret, current+tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, matched := matchTOTP(rfc6238Secret, code, now)
+		if matched != tt.matched {
+			t.Errorf("%s: matched = %v, want %v", tt.name, matched, tt.matched)
+		}
+		if matched && step != current+tt.offset {
+			t.Errorf("%s: step = %d, want %d", tt.name, step, current+tt.offset)
+		}
+	}
+}
+
+func TestMatchTOTPRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	for _, code := range []string{"", "05047", "0050471", "abcdef"} {
+		if _, matched := matchTOTP(rfc6238Secret, code, now); matched {
+			t.Errorf("code %q matched", code)
+		}
+	}
+	if _, matched := matchTOTP("not base32!", "050471", now); matched {
+		t.Error("an invalid secret matched")
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrTransactionNotFound = repository.ErrTransactionNotFound
+
+type TransactionService struct {
+	transactionRepo repository.TransactionRepository
+	userRepo        repository.UserRepository
+}
+
+func NewTransactionService(transactionRepo repository.TransactionRepository, userRepo repository.UserRepository) *TransactionService {
+	return &TransactionService{
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+	}
+}
+
+// GetDetail returns one of the user's transactions. A transaction owned by
+// someone else is reported as not found so IDs cannot be probed.
+func (s *TransactionService) GetDetail(userID, transactionID uuid.UUID) (*models.TransactionDetail, error) {
+	transaction, err := s.transactionRepo.FindByID(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.UserID != userID {
+		return nil, ErrTransactionNotFound
+	}
+
+	balanceAfter, err := s.transactionRepo.BalanceAfter(transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.TransactionDetail{
+		Transaction:  *transaction,
+		ValueIDR:     transaction.Amount.Mul(transaction.PriceAt).Round(2),
+		BalanceAfter: balanceAfter,
+		Related:      []models.Transaction{},
+	}
+
+	if transaction.CounterpartyID != nil {
+		counterparty, err := s.userRepo.FindByID(*transaction.CounterpartyID)
+		if err == nil {
+			detail.Counterparty = &models.TransactionCounterparty{ID: counterparty.ID, Name: counterparty.Name}
+		} else {
+			detail.Counterparty = &models.TransactionCounterparty{ID: *transaction.CounterpartyID}
+		}
+	}
+
+	if transaction.ReferenceID != nil {
+		linked, err := s.transactionRepo.FindByReferenceID(userID, *transaction.ReferenceID)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range linked {
+			if t.ID != transaction.ID {
+				detail.Related = append(detail.Related, t)
+			}
+		}
+	}
+
+	return detail, nil
+}
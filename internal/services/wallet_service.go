@@ -1,33 +1,54 @@
 package services
 
 import (
+	"crypto-wallet-service/config"
 	"crypto-wallet-service/internal/models"
 	"crypto-wallet-service/internal/repository"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrWalletNotFound      = errors.New("wallet not found")
+	ErrWalletFrozen        = errors.New("wallet is frozen")
+	ErrInsufficientBalance = repository.ErrInsufficientBalance
 )
 
 type WalletService struct {
+	userRepo        repository.UserRepository
 	walletRepo      repository.WalletRepository
 	transactionRepo repository.TransactionRepository
-	coinGeckoSvc    *CoinGeckoService
-	db              *gorm.DB
+	priceService    *PriceService
+	mfaService      *MFAService
+	assets          *AssetRegistry
+	uow             repository.UnitOfWork
+
+	withdrawFeePercent decimal.Decimal
 }
 
 func NewWalletService(
+	userRepo repository.UserRepository,
 	walletRepo repository.WalletRepository,
 	transactionRepo repository.TransactionRepository,
-	coinGeckoSvc *CoinGeckoService,
-	db *gorm.DB,
+	priceService *PriceService,
+	mfaService *MFAService,
+	assets *AssetRegistry,
+	uow repository.UnitOfWork,
 ) *WalletService {
 	return &WalletService{
+		userRepo:        userRepo,
 		walletRepo:      walletRepo,
 		transactionRepo: transactionRepo,
-		coinGeckoSvc:    coinGeckoSvc,
-		db:              db,
+		priceService:    priceService,
+		mfaService:      mfaService,
+		assets:          assets,
+		uow:             uow,
+
+		withdrawFeePercent: config.AppConfig.Fee.WithdrawPercent,
 	}
 }
 
@@ -39,135 +60,294 @@ func (s *WalletService) GetOrCreateWallet(userID uuid.UUID, currency string) (*m
 	}
 
 	if wallet == nil {
-		
-		wallet = &models.Wallet{
-			UserID:   userID,
-			Currency: currency,
-			Balance:  0,
-		}
-		if err := s.walletRepo.Create(wallet); err != nil {
+
+		if err := s.walletRepo.CreateIfNotExists(userID, currency); err != nil {
 			return nil, err
 		}
+		return s.walletRepo.FindByUserIDAndCurrency(userID, currency)
 	}
 
 	return wallet, nil
 }
 
 // Deposit adds funds to wallet
-func (s *WalletService) Deposit(userID uuid.UUID, currency string, amount float64) error {
-	if amount <= 0 {
-		return errors.New("amount must be greater than 0")
+func (s *WalletService) Deposit(userID uuid.UUID, currency string, amount decimal.Decimal) error {
+	if _, err := s.assets.ValidateOperation(currency, models.AssetOperationDeposit, amount); err != nil {
+		return err
 	}
 
-	// Start transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Get or create wallet
-	wallet, err := s.GetOrCreateWallet(userID, currency)
+	// Get current price before opening the transaction so no row lock is held
+	// while waiting on the network
+	price, err := s.currentPrice(currency)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	// Update balance
-	newBalance := wallet.Balance + amount
-	if err := s.walletRepo.UpdateBalance(wallet.ID, newBalance); err != nil {
-		tx.Rollback()
-		return err
-	}
+	return s.uow.Do(func(repos repository.Repositories) error {
+		wallet, err := lockWallet(repos.Wallets, userID, currency, true)
+		if err != nil {
+			return err
+		}
+		if wallet.Frozen {
+			return ErrWalletFrozen
+		}
+
+		transaction := &models.Transaction{
+			UserID:    userID,
+			Type:      models.TransactionTypeDeposit,
+			Direction: models.TransactionDirectionCredit,
+			Currency:  currency,
+			Amount:    amount,
+			PriceAt:   price,
+		}
+		if err := repos.Transactions.Create(transaction); err != nil {
+			return err
+		}
 
-	// Get current price
-	price, err := s.coinGeckoSvc.GetPrice(currency)
+		// Funds come in from outside through the clearing account
+		return postEntry(repos, models.JournalEntryDeposit, &transaction.ID, "Deposit",
+			walletLeg(wallet, amount),
+			systemLeg(models.LedgerAccountClearing, currency, amount.Neg()),
+		)
+	})
+}
+
+// Withdraw removes funds from wallet and returns the withdrawal fee charged
+// on top of amount. Withdrawals above the 2FA threshold need a TOTP code.
+func (s *WalletService) Withdraw(userID uuid.UUID, currency string, amount decimal.Decimal, totpCode string) (decimal.Decimal, error) {
+	asset, err := s.assets.ValidateOperation(currency, models.AssetOperationWithdraw, amount)
 	if err != nil {
-		price = 0 // Set to 0 if price fetch fails
+		return decimal.Zero, err
 	}
 
-	// Create transaction record
-	transaction := &models.Transaction{
-		UserID:   userID,
-		Type:     models.TransactionTypeDeposit,
-		Currency: currency,
-		Amount:   amount,
-		PriceAt:  price,
+	fee := s.withdrawFee(asset, amount)
+	price, err := s.currentPrice(currency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if err := s.mfaService.RequireForWithdrawal(userID, amount.Mul(price), totpCode); err != nil {
+		return decimal.Zero, err
 	}
 
-	if err := s.transactionRepo.Create(transaction); err != nil {
-		tx.Rollback()
-		return err
+	err = s.uow.Do(func(repos repository.Repositories) error {
+		wallet, err := lockWallet(repos.Wallets, userID, currency, false)
+		if err != nil {
+			return err
+		}
+		if wallet.Frozen {
+			return ErrWalletFrozen
+		}
+
+		// The row is locked, so no concurrent withdrawal can pass this check
+		// with the same balance
+		if wallet.Balance.LessThan(amount.Add(fee)) {
+			return ErrInsufficientBalance
+		}
+
+		referenceID := uuid.New()
+		transaction := &models.Transaction{
+			UserID:      userID,
+			Type:        models.TransactionTypeWithdraw,
+			Direction:   models.TransactionDirectionDebit,
+			Currency:    currency,
+			Amount:      amount,
+			PriceAt:     price,
+			ReferenceID: &referenceID,
+		}
+		if err := repos.Transactions.Create(transaction); err != nil {
+			return err
+		}
+
+		if err := postEntry(repos, models.JournalEntryWithdraw, &transaction.ID, "Withdrawal",
+			walletLeg(wallet, amount.Neg()),
+			systemLeg(models.LedgerAccountClearing, currency, amount),
+		); err != nil {
+			return err
+		}
+
+		if !fee.IsPositive() {
+			return nil
+		}
+
+		feeTransaction := &models.Transaction{
+			UserID:      userID,
+			Type:        models.TransactionTypeFee,
+			Direction:   models.TransactionDirectionDebit,
+			Currency:    currency,
+			Amount:      fee,
+			PriceAt:     price,
+			ReferenceID: &referenceID,
+		}
+		if err := repos.Transactions.Create(feeTransaction); err != nil {
+			return err
+		}
+
+		return postEntry(repos, models.JournalEntryFee, &feeTransaction.ID, "Withdrawal fee",
+			walletLeg(wallet, fee.Neg()),
+			systemLeg(models.LedgerAccountFeeIncome, currency, fee),
+		)
+	})
+	if err != nil {
+		return decimal.Zero, err
 	}
 
-	return tx.Commit().Error
+	return fee, nil
 }
 
-// Withdraw removes funds from wallet
-func (s *WalletService) Withdraw(userID uuid.UUID, currency string, amount float64) error {
-	if amount <= 0 {
-		return errors.New("amount must be greater than 0")
+// Transfer moves funds from the sender's wallet to the recipient's wallet.
+// Both balance updates and the linked debit/credit transaction rows are
+// written in a single database transaction.
+func (s *WalletService) Transfer(senderID uuid.UUID, recipient string, currency string, amount decimal.Decimal, totpCode string) (*models.Transaction, error) {
+	if _, err := s.assets.ValidateOperation(currency, models.AssetOperationTransfer, amount); err != nil {
+		return nil, err
 	}
 
-	// Start transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Get wallet
-	wallet, err := s.walletRepo.FindByUserIDAndCurrency(userID, currency)
+	receiver, err := s.findRecipient(recipient)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
+	}
+	if receiver.ID == senderID {
+		return nil, errors.New("cannot transfer to yourself")
 	}
 
-	if wallet == nil {
-		tx.Rollback()
-		return errors.New("wallet not found")
+	price, err := s.currentPrice(currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaService.RequireForWithdrawal(senderID, amount.Mul(price), totpCode); err != nil {
+		return nil, err
 	}
 
-	// Check if sufficient balance
-	if wallet.Balance < amount {
-		tx.Rollback()
-		return errors.New("insufficient balance")
+	var debit *models.Transaction
+	err = s.uow.Do(func(repos repository.Repositories) error {
+		if err := repos.Wallets.CreateIfNotExists(receiver.ID, currency); err != nil {
+			return err
+		}
+
+		// Lock both wallets in a stable order so two opposite transfers
+		// between the same users cannot deadlock
+		first, second := senderID, receiver.ID
+		if second.String() < first.String() {
+			first, second = second, first
+		}
+		locked := make(map[uuid.UUID]*models.Wallet, 2)
+		for _, id := range []uuid.UUID{first, second} {
+			wallet, err := lockWallet(repos.Wallets, id, currency, false)
+			if err != nil {
+				return err
+			}
+			if wallet.Frozen {
+				return ErrWalletFrozen
+			}
+			locked[id] = wallet
+		}
+
+		senderWallet, receiverWallet := locked[senderID], locked[receiver.ID]
+		if senderWallet.Balance.LessThan(amount) {
+			return ErrInsufficientBalance
+		}
+
+		referenceID := uuid.New()
+		debit = &models.Transaction{
+			UserID:         senderID,
+			Type:           models.TransactionTypeTransfer,
+			Direction:      models.TransactionDirectionDebit,
+			Currency:       currency,
+			Amount:         amount,
+			PriceAt:        price,
+			CounterpartyID: &receiver.ID,
+			ReferenceID:    &referenceID,
+		}
+		credit := &models.Transaction{
+			UserID:         receiver.ID,
+			Type:           models.TransactionTypeTransfer,
+			Direction:      models.TransactionDirectionCredit,
+			Currency:       currency,
+			Amount:         amount,
+			PriceAt:        price,
+			CounterpartyID: &senderID,
+			ReferenceID:    &referenceID,
+		}
+
+		if err := repos.Transactions.Create(debit); err != nil {
+			return err
+		}
+		if err := repos.Transactions.Create(credit); err != nil {
+			return err
+		}
+
+		return postEntry(repos, models.JournalEntryTransfer, &debit.ID, "Transfer",
+			walletLeg(senderWallet, amount.Neg()),
+			walletLeg(receiverWallet, amount),
+		)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Update balance
-	newBalance := wallet.Balance - amount
-	if err := s.walletRepo.UpdateBalance(wallet.ID, newBalance); err != nil {
-		tx.Rollback()
-		return err
+	return debit, nil
+}
+
+// lockWallet loads a wallet row with FOR UPDATE inside the current unit of
+// work, creating an empty wallet first when create is set
+func lockWallet(wallets repository.WalletRepository, userID uuid.UUID, currency string, create bool) (*models.Wallet, error) {
+	if create {
+		if err := wallets.CreateIfNotExists(userID, currency); err != nil {
+			return nil, err
+		}
 	}
 
-	// Get current price
-	price, err := s.coinGeckoSvc.GetPrice(currency)
+	wallet, err := wallets.FindByUserIDAndCurrencyForUpdate(userID, currency)
 	if err != nil {
-		price = 0
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, ErrWalletNotFound
 	}
 
-	// Create transaction record
-	transaction := &models.Transaction{
-		UserID:   userID,
-		Type:     models.TransactionTypeWithdraw,
-		Currency: currency,
-		Amount:   amount,
-		PriceAt:  price,
+	return wallet, nil
+}
+
+// withdrawFee computes the configured percentage fee, rounded down to the
+// asset's precision
+func (s *WalletService) withdrawFee(asset models.Asset, amount decimal.Decimal) decimal.Decimal {
+	if !s.withdrawFeePercent.IsPositive() {
+		return decimal.Zero
 	}
 
-	if err := s.transactionRepo.Create(transaction); err != nil {
-		tx.Rollback()
-		return err
+	return amount.Mul(s.withdrawFeePercent).Div(decimal.NewFromInt(100)).Truncate(asset.Decimals)
+}
+
+// currentPrice returns the IDR price used for the transaction record. A stale
+// price within the configured limit is accepted; beyond it the operation
+// fails with ErrPriceUnavailable instead of recording a zero price.
+func (s *WalletService) currentPrice(currency string) (decimal.Decimal, error) {
+	return s.priceService.GetPrice(currency)
+}
+
+// findRecipient resolves a transfer recipient given either a user ID or an email
+func (s *WalletService) findRecipient(recipient string) (*models.User, error) {
+	recipient = strings.TrimSpace(recipient)
+
+	var (
+		user *models.User
+		err  error
+	)
+	if id, parseErr := uuid.Parse(recipient); parseErr == nil {
+		user, err = s.userRepo.FindByID(id)
+	} else {
+		user, err = s.userRepo.FindByEmail(recipient)
+	}
+	if err != nil {
+		return nil, errors.New("recipient not found")
 	}
 
-	return tx.Commit().Error
+	return user, nil
 }
 
-// GetPortfolio returns user's portfolio with current prices
-func (s *WalletService) GetPortfolio(userID uuid.UUID) (*models.PortfolioResponse, error) {
+// GetPortfolio returns user's portfolio with current prices and P&L computed
+// with the given cost method
+func (s *WalletService) GetPortfolio(userID uuid.UUID, method models.CostMethod) (*models.PortfolioResponse, error) {
 	// Get all wallets
 	wallets, err := s.walletRepo.FindAllByUserID(userID)
 	if err != nil {
@@ -175,34 +355,48 @@ func (s *WalletService) GetPortfolio(userID uuid.UUID) (*models.PortfolioRespons
 	}
 
 	var assets []models.WalletWithPrice
-	var totalValueIDR float64
+	totalValueIDR := decimal.Zero
+	stale := false
 
 	for _, wallet := range wallets {
-		if wallet.Balance == 0 {
+		if wallet.Balance.IsZero() {
 			continue // Skip empty wallets
 		}
 
-		// Get current price
-		price, err := s.coinGeckoSvc.GetPrice(wallet.Currency)
+		// Get current price, possibly the last known good one
+		quote, err := s.priceService.Quote(wallet.Currency)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get price for %s: %w", wallet.Currency, err)
 		}
 
-		valueIDR := wallet.Balance * price
+		valueIDR := wallet.Balance.Mul(quote.Price).Round(2)
 
-		assets = append(assets, models.WalletWithPrice{
+		asset := models.WalletWithPrice{
 			Currency: wallet.Currency,
 			Balance:  wallet.Balance,
-			PriceIDR: price,
+			PriceIDR: quote.Price,
 			ValueIDR: valueIDR,
-		})
+		}
+		if quote.Stale {
+			asset.PriceStale = true
+			asset.PriceAgeSeconds = quote.AgeSeconds
+			stale = true
+		}
+		assets = append(assets, asset)
+
+		totalValueIDR = totalValueIDR.Add(valueIDR)
+	}
 
-		totalValueIDR += valueIDR
+	pnl, err := s.GetPnL(userID, method)
+	if err != nil {
+		return nil, err
 	}
 
 	return &models.PortfolioResponse{
 		Assets:        assets,
 		TotalValueIDR: totalValueIDR,
+		PricesStale:   stale,
+		PnL:           pnl,
 	}, nil
 }
 
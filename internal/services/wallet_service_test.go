@@ -0,0 +1,145 @@
+package services
+
+import (
+	"crypto-wallet-service/internal/models"
+	"crypto-wallet-service/internal/repository"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func newTestWalletService(t *testing.T) (*WalletService, repository.Repositories) {
+	t.Helper()
+
+	db := openTestDB(t)
+	repos := repository.Repositories{
+		Users:        repository.NewUserRepository(db),
+		Wallets:      repository.NewWalletRepository(db),
+		Transactions: repository.NewTransactionRepository(db),
+		Ledger:       repository.NewLedgerRepository(db),
+	}
+
+	assets := NewAssetRegistry(repository.NewAssetRepository(db))
+	if err := assets.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// IDR is priced at 1 without a provider, and the amounts used here stay
+	// below the 2FA threshold, so neither Redis nor a price feed is needed
+	priceService := NewPriceService(nil, assets, nil, nil)
+	mfaService := NewMFAService(repos.Users, repository.NewRecoveryCodeRepository(db), nil, nil)
+
+	service := NewWalletService(repos.Users, repos.Wallets, repos.Transactions, priceService, mfaService, assets, repository.NewUnitOfWork(db))
+	return service, repos
+}
+
+func createTestUser(t *testing.T, users repository.UserRepository) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		Name:     "Test User",
+		Email:    uuid.NewString() + "@example.com",
+		Password: "x",
+	}
+	if err := users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// TestWalletConcurrentWithdrawalsNeverOverdraw fires more withdrawals and
+// transfers at one wallet than its balance covers and checks that exactly
+// the affordable ones succeed and the ledger still matches the wallet
+func TestWalletConcurrentWithdrawalsNeverOverdraw(t *testing.T) {
+	service, repos := newTestWalletService(t)
+
+	const (
+		currency = "IDR"
+		workers  = 20
+	)
+	var (
+		deposit = decimal.NewFromInt(1000)
+		amount  = decimal.NewFromInt(100)
+	)
+
+	sender := createTestUser(t, repos.Users)
+	recipient := createTestUser(t, repos.Users)
+	if err := service.Deposit(sender.ID, currency, deposit); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			var err error
+			if i%2 == 0 {
+				_, err = service.Withdraw(sender.ID, currency, amount, "")
+			} else {
+				_, err = service.Transfer(sender.ID, recipient.Email, currency, amount, "")
+			}
+
+			switch {
+			case err == nil:
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			case !errors.Is(err, ErrInsufficientBalance):
+				t.Errorf("worker %d: unexpected error: %v", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if want := int(deposit.Div(amount).IntPart()); succeeded != want {
+		t.Errorf("succeeded = %d, want %d", succeeded, want)
+	}
+
+	senderWallet, err := repos.Wallets.FindByUserIDAndCurrency(sender.ID, currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if senderWallet.Balance.IsNegative() {
+		t.Fatalf("sender balance is negative: %s", senderWallet.Balance)
+	}
+	want := deposit.Sub(amount.Mul(decimal.NewFromInt(int64(succeeded))))
+	if !senderWallet.Balance.Equal(want) {
+		t.Errorf("sender balance = %s, want %s", senderWallet.Balance, want)
+	}
+
+	ledgerBalances, err := repos.Ledger.UserWalletBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := make(map[uuid.UUID]decimal.Decimal)
+	for _, balance := range ledgerBalances {
+		if balance.Currency == currency {
+			ledger[balance.UserID] = balance.Balance
+		}
+	}
+	for _, user := range []*models.User{sender, recipient} {
+		wallet, err := repos.Wallets.FindByUserIDAndCurrency(user.ID, currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		balance := decimal.Zero
+		if wallet != nil {
+			balance = wallet.Balance
+		}
+		if !balance.Equal(ledger[user.ID]) {
+			t.Errorf("user %s: wallet balance %s does not match ledger %s", user.ID, balance, ledger[user.ID])
+		}
+	}
+}